@@ -2,11 +2,17 @@ package standard_processors_bundle
 
 import (
 	"fmt"
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpregistry"
 	"github.com/go-streamline/standard-processors-bundle/processors"
+	"github.com/go-streamline/standard-processors-bundle/processors/fetchhttp"
+	"github.com/go-streamline/standard-processors-bundle/processors/handlehttpresponse"
 	"github.com/go-streamline/standard-processors-bundle/processors/io"
+	"github.com/go-streamline/standard-processors-bundle/processors/kafka"
 	"github.com/go-streamline/standard-processors-bundle/processors/pubsub"
 	"github.com/go-streamline/standard-processors-bundle/processors/uploadhttp"
+	thttp "github.com/go-streamline/standard-processors-bundle/tprocessors/http"
 	tio "github.com/go-streamline/standard-processors-bundle/tprocessors/io"
 	tkafka "github.com/go-streamline/standard-processors-bundle/tprocessors/kafka"
 	tpubsub "github.com/go-streamline/standard-processors-bundle/tprocessors/pubsub"
@@ -19,28 +25,59 @@ var (
 
 type Factory struct {
 	stateManagerFactory definitions.StateManagerFactory
+	exprOptions         []expr.Option
+	// httpRegistry pairs the requests of HandleHTTPRequest with the HandleHTTPResponse answering them
+	httpRegistry *httpregistry.Registry
 }
 
-func Create(stateManagerFactory definitions.StateManagerFactory) definitions.ProcessorFactory {
-	return &Factory{
+// Option configures the Factory returned by Create.
+type Option func(*Factory)
+
+// WithExprFunction registers a custom function under the given name, making it
+// available to the expressions of every processor built by the factory. ConsumeKafka,
+// ListenHTTP and HandleHTTPRequest have no expressions in their configuration, so they are
+// built without the functions. It panics if name is one of the state helpers of
+// UpdateMetadata.
+func WithExprFunction(name string, fn func(params ...any) (any, error)) Option {
+	if name == processors.GetStateFunction || name == processors.SetStateFunction {
+		panic(fmt.Sprintf("expression function %s is reserved", name))
+	}
+	return func(f *Factory) {
+		f.exprOptions = append(f.exprOptions, expr.Function(name, fn))
+	}
+}
+
+func Create(stateManagerFactory definitions.StateManagerFactory, opts ...Option) definitions.ProcessorFactory {
+	f := &Factory{
 		stateManagerFactory: stateManagerFactory,
+		httpRegistry:        httpregistry.New(),
+	}
+	for _, opt := range opts {
+		opt(f)
 	}
+	return f
 }
 
 func (f *Factory) GetProcessor(id uuid.UUID, typeName string) (definitions.Processor, error) {
 	switch typeName {
 	case (&io.ReadFile{}).Name():
-		return io.NewReadFile(), nil
+		return io.NewReadFile(f.exprOptions...), nil
 	case (&io.WriteFile{}).Name():
-		return io.NewWriteFile(), nil
+		return io.NewWriteFile(f.exprOptions...), nil
 	case (&uploadhttp.UploadHTTP{}).Name():
-		return uploadhttp.NewUploadHTTP(), nil
+		return uploadhttp.NewUploadHTTP(f.stateManagerFactory.CreateStateManager(id), f.exprOptions...), nil
+	case (&fetchhttp.FetchHTTP{}).Name():
+		return fetchhttp.NewFetchHTTP(f.exprOptions...), nil
+	case (&handlehttpresponse.HandleHTTPResponse{}).Name():
+		return handlehttpresponse.NewHandleHTTPResponse(f.httpRegistry, f.exprOptions...), nil
 	case (&processors.RunExecutable{}).Name():
-		return processors.NewRunExecutable(), nil
+		return processors.NewRunExecutable(f.exprOptions...), nil
+	case (&kafka.PublishKafka{}).Name():
+		return kafka.NewPublishKafka(f.exprOptions...), nil
 	case (&pubsub.PublishPubSub{}).Name():
-		return pubsub.NewPublishPubSub(), nil
+		return pubsub.NewPublishPubSub(f.exprOptions...), nil
 	case (&processors.UpdateMetadata{}).Name():
-		return processors.NewUpdateMetadata(f.stateManagerFactory.CreateStateManager(id)), nil
+		return processors.NewUpdateMetadata(f.stateManagerFactory.CreateStateManager(id), f.exprOptions...), nil
 	default:
 		return nil, ErrUnsupportedProcessorType
 	}
@@ -49,11 +86,18 @@ func (f *Factory) GetProcessor(id uuid.UUID, typeName string) (definitions.Proce
 func (f *Factory) GetTriggerProcessor(id uuid.UUID, typeName string) (definitions.TriggerProcessor, error) {
 	switch typeName {
 	case (&tio.ReadDir{}).Name():
-		return tio.NewReadDir(f.stateManagerFactory.CreateStateManager(id)), nil
+		return tio.NewReadDir(f.stateManagerFactory.CreateStateManager(id), f.exprOptions...), nil
 	case (&tkafka.ConsumeKafka{}).Name():
+		// ConsumeKafka, ListenHTTP and HandleHTTPRequest have no expressions
 		return tkafka.NewConsumeKafka(), nil
 	case (&tpubsub.ConsumePubSub{}).Name():
-		return tpubsub.NewConsumePubSub(), nil
+		return tpubsub.NewConsumePubSub(f.exprOptions...), nil
+	case (&thttp.ListenHTTP{}).Name():
+		return thttp.NewListenHTTP(f.stateManagerFactory.CreateStateManager(id)), nil
+	case (&thttp.HandleHTTPRequest{}).Name():
+		return thttp.NewHandleHTTPRequest(f.stateManagerFactory.CreateStateManager(id), f.httpRegistry), nil
+	case (&thttp.PollHTTP{}).Name():
+		return thttp.NewPollHTTP(f.stateManagerFactory.CreateStateManager(id), f.exprOptions...), nil
 	default:
 		return nil, ErrUnsupportedProcessorType
 	}
@@ -0,0 +1,62 @@
+package standard_processors_bundle
+
+import (
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type mockStateManager struct {
+	state map[string]any
+}
+
+func (m *mockStateManager) GetState(definitions.StateType) (map[string]any, error) {
+	return m.state, nil
+}
+
+func (m *mockStateManager) SetState(_ definitions.StateType, state map[string]any) error {
+	m.state = state
+	return nil
+}
+
+type mockStateManagerFactory struct{}
+
+func (mockStateManagerFactory) CreateStateManager(uuid.UUID) definitions.StateManager {
+	return &mockStateManager{state: map[string]any{}}
+}
+
+func TestCreate_WithExprFunction(t *testing.T) {
+	factory := Create(mockStateManagerFactory{}, WithExprFunction("tenantOf", func(params ...any) (any, error) {
+		return fmt.Sprintf("tenant-%v", params[0]), nil
+	}))
+
+	processor, err := factory.GetProcessor(uuid.New(), "UpdateMetadata")
+	assert.NoError(t, err)
+	err = processor.SetConfig(map[string]interface{}{"Tenant": "${tenantOf(CustomerID)}"})
+	assert.NoError(t, err)
+
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{"CustomerID": 42}}
+	result, err := processor.Execute(info, nil, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "tenant-42", result.Metadata["Tenant"])
+
+	// processors built without the option do not know the function
+	processor, err = Create(mockStateManagerFactory{}).GetProcessor(uuid.New(), "UpdateMetadata")
+	assert.NoError(t, err)
+	err = processor.SetConfig(map[string]interface{}{"Tenant": "${tenantOf(CustomerID)}"})
+	assert.NoError(t, err)
+	info = &definitions.EngineFlowObject{Metadata: map[string]interface{}{"CustomerID": 42}}
+	_, err = processor.Execute(info, nil, logrus.New())
+	assert.Error(t, err)
+}
+
+func TestCreate_WithExprFunction_Reserved(t *testing.T) {
+	for _, name := range []string{"getState", "setState"} {
+		assert.PanicsWithValue(t, fmt.Sprintf("expression function %s is reserved", name), func() {
+			WithExprFunction(name, func(params ...any) (any, error) { return nil, nil })
+		})
+	}
+}
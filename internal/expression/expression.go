@@ -0,0 +1,175 @@
+package expression
+
+import (
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"strings"
+)
+
+const (
+	placeholderStart = "${"
+	placeholderEnd   = "}"
+)
+
+// Expression is a configuration value whose ${...} placeholders were compiled into expr programs
+// once, so evaluating it for a flow object only runs the programs against the metadata.
+type Expression struct {
+	raw   string
+	parts []part
+}
+
+type part struct {
+	literal string
+	program *vm.Program
+}
+
+// Compile parses the ${...} placeholders of input and compiles each of them with the given options.
+func Compile(input string, options ...expr.Option) (*Expression, error) {
+	e := &Expression{raw: input}
+	rest := input
+	for {
+		start := strings.Index(rest, placeholderStart)
+		if start < 0 {
+			break
+		}
+		end := placeholderLength(rest[start+len(placeholderStart):])
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated placeholder in expression %q", input)
+		}
+		end += start + len(placeholderStart)
+
+		if start > 0 {
+			e.parts = append(e.parts, part{literal: rest[:start]})
+		}
+		code := rest[start+len(placeholderStart) : end]
+		program, err := expr.Compile(code, options...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile expression %q: %w", code, err)
+		}
+		e.parts = append(e.parts, part{program: program})
+		rest = rest[end+len(placeholderEnd):]
+	}
+	if rest != "" {
+		e.parts = append(e.parts, part{literal: rest})
+	}
+	return e, nil
+}
+
+// placeholderLength returns the length of the code of a placeholder up to its closing brace, or -1 if
+// it is never closed. Braces of map literals and braces within string literals are part of the code.
+func placeholderLength(code string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote != '`' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '{':
+			depth++
+		case c == placeholderEnd[0]:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// CompileSlice compiles every value of inputs, preserving their order.
+func CompileSlice(inputs []string, options ...expr.Option) ([]*Expression, error) {
+	compiled := make([]*Expression, len(inputs))
+	for i, input := range inputs {
+		e, err := Compile(input, options...)
+		if err != nil {
+			return nil, err
+		}
+		compiled[i] = e
+	}
+	return compiled, nil
+}
+
+// String returns the expression as it was written in the configuration.
+func (e *Expression) String() string {
+	return e.raw
+}
+
+// IsLiteral reports whether the expression has no placeholders and always evaluates to itself.
+func (e *Expression) IsLiteral() bool {
+	for _, p := range e.parts {
+		if p.program != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Evaluate runs the compiled placeholders against metadata and returns the resulting string.
+func (e *Expression) Evaluate(metadata map[string]interface{}) (string, error) {
+	if len(e.parts) == 1 && e.parts[0].program == nil {
+		return e.parts[0].literal, nil
+	}
+	if metadata == nil {
+		metadata = map[string]interface{}{}
+	}
+
+	var sb strings.Builder
+	for _, p := range e.parts {
+		if p.program == nil {
+			sb.WriteString(p.literal)
+			continue
+		}
+		value, err := expr.Run(p.program, metadata)
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate expression %q: %w", e.raw, err)
+		}
+		sb.WriteString(fmt.Sprintf("%v", value))
+	}
+	return sb.String(), nil
+}
+
+// Header is a header whose name and value are both expressions.
+type Header struct {
+	Key   *Expression
+	Value *Expression
+}
+
+// CompileHeaders compiles the names and values of headers.
+func CompileHeaders(headers map[string]string, options ...expr.Option) ([]Header, error) {
+	compiled := make([]Header, 0, len(headers))
+	for key, value := range headers {
+		var header Header
+		var err error
+		header.Key, err = Compile(key, options...)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		header.Value, err = Compile(value, options...)
+		if err != nil {
+			return nil, fmt.Errorf("value of %s: %w", key, err)
+		}
+		compiled = append(compiled, header)
+	}
+	return compiled, nil
+}
+
+// Evaluate returns the name and value of the header for metadata.
+func (h Header) Evaluate(metadata map[string]interface{}) (string, string, error) {
+	key, err := h.Key.Evaluate(metadata)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to evaluate header key: %w", err)
+	}
+	value, err := h.Value.Evaluate(metadata)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to evaluate header value: %w", err)
+	}
+	return key, value, nil
+}
@@ -0,0 +1,141 @@
+package expression
+
+import (
+	"github.com/expr-lang/expr"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompile_Literal(t *testing.T) {
+	e, err := Compile("http://example.com/upload")
+	assert.NoError(t, err)
+	assert.True(t, e.IsLiteral())
+
+	value, err := e.Evaluate(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/upload", value)
+}
+
+func TestCompile_Placeholders(t *testing.T) {
+	e, err := Compile("/data/${tenant}/${id + 1}.json")
+	assert.NoError(t, err)
+	assert.False(t, e.IsLiteral())
+
+	value, err := e.Evaluate(map[string]interface{}{"tenant": "acme", "id": 41})
+	assert.NoError(t, err)
+	assert.Equal(t, "/data/acme/42.json", value)
+}
+
+func TestCompile_Options(t *testing.T) {
+	e, err := Compile("${upper(name)}", expr.Function("upper", func(params ...any) (any, error) {
+		return "UP-" + params[0].(string), nil
+	}))
+	assert.NoError(t, err)
+
+	value, err := e.Evaluate(map[string]interface{}{"name": "file"})
+	assert.NoError(t, err)
+	assert.Equal(t, "UP-file", value)
+}
+
+// TestCompile_Matches_EvaluateExpression checks that compiling an expression once gives the same
+// result as evaluating it for every flow object with EngineFlowObject.EvaluateExpression.
+func TestCompile_Matches_EvaluateExpression(t *testing.T) {
+	options := []expr.Option{expr.Function("upper", func(params ...any) (any, error) {
+		return "UP-" + params[0].(string), nil
+	})}
+	metadata := map[string]interface{}{
+		"tenant":  "acme",
+		"id":      41,
+		"ratio":   0.5,
+		"enabled": true,
+		"tags":    []string{"a", "b"},
+		"nested":  map[string]interface{}{"name": "inner"},
+	}
+	for _, input := range []string{
+		"",
+		"plain text",
+		"${tenant}",
+		"/data/${tenant}/${id + 1}.json",
+		"${tenant}${id}",
+		"${id * ratio}",
+		"${enabled && id > 40}",
+		"${tags}",
+		"${len(tags)}",
+		"${nested.name}",
+		`${tenant + "-" + "suffix"}`,
+		"${upper(tenant)}",
+		"${id > 40 ? 'big' : 'small'}",
+		"prefix $ {not a placeholder} ${tenant}",
+	} {
+		info := &definitions.EngineFlowObject{Metadata: metadata}
+		expected, err := info.EvaluateExpression(input, options...)
+		assert.NoError(t, err, input)
+
+		e, err := Compile(input, options...)
+		assert.NoError(t, err, input)
+		value, err := e.Evaluate(metadata)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, value, input)
+	}
+}
+
+func TestCompile_Braces(t *testing.T) {
+	metadata := map[string]interface{}{"x": "value"}
+	for input, expected := range map[string]string{
+		`${ {"a": 1}.a }`:           "1",
+		`${ {"a": {"b": x}}.a.b }/`: "value/",
+		`${ "}" + x }`:              "}value",
+		`${ '{' + x }`:              "{value",
+		"${ `}` + x }":              "}value",
+		`${ "\"}" + x }`:            `"}value`,
+		`{literal} ${x} {literal}`:  "{literal} value {literal}",
+	} {
+		e, err := Compile(input)
+		assert.NoError(t, err, input)
+		value, err := e.Evaluate(metadata)
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, value, input)
+	}
+
+	_, err := Compile(`${ {"a": 1}.a`)
+	assert.Error(t, err)
+	_, err = Compile(`${ "}" `)
+	assert.Error(t, err)
+}
+
+func TestCompile_SyntaxError(t *testing.T) {
+	_, err := Compile("${id +}")
+	assert.Error(t, err)
+
+	_, err = Compile("/data/${tenant")
+	assert.Error(t, err)
+}
+
+const benchmarkInput = "http://example.com/${tenant}/upload/${id}"
+
+var benchmarkMetadata = map[string]interface{}{"tenant": "acme", "id": 42}
+
+func BenchmarkEvaluateExpression(b *testing.B) {
+	info := &definitions.EngineFlowObject{Metadata: benchmarkMetadata}
+	for i := 0; i < b.N; i++ {
+		_, err := info.EvaluateExpression(benchmarkInput)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiledExpression(b *testing.B) {
+	e, err := Compile(benchmarkInput)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := e.Evaluate(benchmarkMetadata)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
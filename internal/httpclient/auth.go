@@ -0,0 +1,210 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// AuthType is the kind of authentication added to the requests.
+type AuthType string
+
+const (
+	AuthNone   AuthType = ""
+	AuthBasic  AuthType = "basic"
+	AuthBearer AuthType = "bearer"
+	AuthOAuth2 AuthType = "oauth2"
+	AuthAPIKey AuthType = "api_key"
+)
+
+type apiKeyLocation string
+
+const (
+	apiKeyInHeader apiKeyLocation = "header"
+	apiKeyInQuery  apiKeyLocation = "query"
+)
+
+// AuthConfig holds the credentials of one of the auth types.
+type AuthConfig struct {
+	Type           AuthType          `mapstructure:"type"`
+	Username       string            `mapstructure:"username,omitempty"`
+	Password       string            `mapstructure:"password,omitempty"`
+	Token          string            `mapstructure:"token,omitempty"`
+	TokenFile      string            `mapstructure:"token_file,omitempty"`
+	TokenURL       string            `mapstructure:"token_url,omitempty"`
+	ClientID       string            `mapstructure:"client_id,omitempty"`
+	ClientSecret   string            `mapstructure:"client_secret,omitempty"`
+	Scopes         []string          `mapstructure:"scopes,omitempty"`
+	EndpointParams map[string]string `mapstructure:"endpoint_params,omitempty"`
+	APIKey         string            `mapstructure:"api_key,omitempty"`
+	APIKeyName     string            `mapstructure:"api_key_name,omitempty"`
+	APIKeyIn       apiKeyLocation    `mapstructure:"api_key_in,omitempty"`
+}
+
+// Authenticator adds the credentials of the configured auth type to every attempt.
+type Authenticator struct {
+	config      AuthConfig
+	username    *expression.Expression
+	password    *expression.Expression
+	token       *expression.Expression
+	apiKey      *expression.Expression
+	tokenSource oauth2.TokenSource
+}
+
+func NewAuthenticator(ctx context.Context, conf AuthConfig, exprOptions ...expr.Option) (*Authenticator, error) {
+	var err error
+	a := &Authenticator{config: conf}
+	switch conf.Type {
+	case AuthNone:
+	case AuthBasic:
+		if conf.Username == "" {
+			return nil, fmt.Errorf("username is required for basic auth")
+		}
+		a.username, err = expression.Compile(conf.Username, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("username: %w", err)
+		}
+		a.password, err = expression.Compile(conf.Password, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("password: %w", err)
+		}
+	case AuthBearer:
+		if (conf.Token == "") == (conf.TokenFile == "") {
+			return nil, fmt.Errorf("exactly one of token or token_file is required for bearer auth")
+		}
+		a.token, err = expression.Compile(conf.Token, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("token: %w", err)
+		}
+	case AuthOAuth2:
+		if conf.TokenURL == "" || conf.ClientID == "" {
+			return nil, fmt.Errorf("token_url and client_id are required for oauth2 auth")
+		}
+		params := url.Values{}
+		for k, v := range conf.EndpointParams {
+			params.Set(k, v)
+		}
+		credentials := &clientcredentials.Config{
+			ClientID:       conf.ClientID,
+			ClientSecret:   conf.ClientSecret,
+			TokenURL:       conf.TokenURL,
+			Scopes:         conf.Scopes,
+			EndpointParams: params,
+		}
+		// the token source caches the token and only fetches a new one once it expires
+		a.tokenSource = credentials.TokenSource(ctx)
+	case AuthAPIKey:
+		if conf.APIKeyName == "" {
+			return nil, fmt.Errorf("api_key_name is required for api_key auth")
+		}
+		if conf.APIKeyIn == "" {
+			a.config.APIKeyIn = apiKeyInHeader
+		}
+		if a.config.APIKeyIn != apiKeyInHeader && a.config.APIKeyIn != apiKeyInQuery {
+			return nil, fmt.Errorf("unsupported api_key_in %s", conf.APIKeyIn)
+		}
+		a.apiKey, err = expression.Compile(conf.APIKey, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("api_key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported auth type %s", conf.Type)
+	}
+	return a, nil
+}
+
+// Apply sets the credentials on req. Secrets are never logged.
+func (a *Authenticator) Apply(req *http.Request, info *definitions.EngineFlowObject) error {
+	switch a.config.Type {
+	case AuthBasic:
+		username, err := a.username.Evaluate(info.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate username: %w", err)
+		}
+		password, err := a.password.Evaluate(info.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate password: %w", err)
+		}
+		req.SetBasicAuth(username, password)
+	case AuthBearer:
+		token, err := a.bearerToken(info)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case AuthOAuth2:
+		token, err := a.tokenSource.Token()
+		if err != nil {
+			return fmt.Errorf("failed to get oauth2 token: %w", err)
+		}
+		token.SetAuthHeader(req)
+	case AuthAPIKey:
+		apiKey, err := a.apiKey.Evaluate(info.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate api key: %w", err)
+		}
+		if a.config.APIKeyIn == apiKeyInQuery {
+			query := req.URL.Query()
+			query.Set(a.config.APIKeyName, apiKey)
+			req.URL.RawQuery = query.Encode()
+		} else {
+			req.Header.Set(a.config.APIKeyName, apiKey)
+		}
+	}
+	return nil
+}
+
+func (a *Authenticator) bearerToken(info *definitions.EngineFlowObject) (string, error) {
+	if a.config.TokenFile != "" {
+		// read on every request so rotated tokens are picked up
+		token, err := os.ReadFile(a.config.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token file: %w", err)
+		}
+		return strings.TrimSpace(string(token)), nil
+	}
+	token, err := a.token.Evaluate(info.Metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate token: %w", err)
+	}
+	return token, nil
+}
+
+// RedactHeaders returns a copy of header that is safe to log.
+func (a *Authenticator) RedactHeaders(header http.Header) http.Header {
+	sensitive := []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Amz-Security-Token"}
+	if a.config.Type == AuthAPIKey && a.config.APIKeyIn == apiKeyInHeader {
+		sensitive = append(sensitive, a.config.APIKeyName)
+	}
+	redactedHeader := header.Clone()
+	for _, key := range sensitive {
+		if redactedHeader.Get(key) != "" {
+			redactedHeader.Set(key, redacted)
+		}
+	}
+	return redactedHeader
+}
+
+// RedactURL returns u as a string that is safe to log.
+func (a *Authenticator) RedactURL(u *url.URL) string {
+	if a.config.Type != AuthAPIKey || a.config.APIKeyIn != apiKeyInQuery {
+		return u.Redacted()
+	}
+	redactedURL := *u
+	query := redactedURL.Query()
+	if query.Has(a.config.APIKeyName) {
+		query.Set(a.config.APIKeyName, redacted)
+	}
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.Redacted()
+}
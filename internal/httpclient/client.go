@@ -0,0 +1,64 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-streamline/interfaces/utils"
+	"golang.org/x/oauth2"
+	"io"
+	"net/http"
+)
+
+// MaxLoggedBodySize bounds the part of request and response bodies written to the logs.
+const MaxLoggedBodySize = 1024
+
+// Configure returns the client of a processor for conf and tlsConf, along with the context its
+// OAuth2 token requests are sent with. A client injected before the processor was first configured,
+// which comes without a transport, is kept as is. Otherwise the previous transport is closed and the
+// client is built again, with customize applied to it.
+func Configure(
+	client utils.HTTPClient,
+	transport *http.Transport,
+	conf TransportConfig,
+	tlsConf TLSConfig,
+	customize ...func(*http.Client),
+) (utils.HTTPClient, *http.Transport, context.Context, error) {
+	ctx := context.Background()
+	if client != nil && transport == nil {
+		return client, nil, ctx, nil
+	}
+	newClient, newTransport, err := NewClient(conf, tlsConf)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, c := range customize {
+		c(newClient)
+	}
+	if transport != nil {
+		transport.CloseIdleConnections()
+	}
+	return newClient, newTransport, context.WithValue(ctx, oauth2.HTTPClient, newClient), nil
+}
+
+// LimitBody bounds body to limit bytes, or leaves it as is if limit is not positive. It lets one
+// byte past the limit through, so CheckBodySize can tell a body of exactly the limit from a larger one.
+func LimitBody(body io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return body
+	}
+	return io.LimitReader(body, limit+1)
+}
+
+// CheckBodySize fails if read, the number of bytes read from a body bounded by LimitBody, is past limit.
+func CheckBodySize(read, limit int64) error {
+	if limit > 0 && read > limit {
+		return fmt.Errorf("response body exceeds max_response_body of %d bytes", limit)
+	}
+	return nil
+}
+
+// ErrorBody returns the start of the body of an error response, to be logged along with its status.
+func ErrorBody(body io.Reader) string {
+	start, _ := io.ReadAll(io.LimitReader(body, MaxLoggedBodySize))
+	return string(start)
+}
@@ -0,0 +1,84 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"os"
+)
+
+// TLSConfig holds the TLS settings of the connections of a client.
+type TLSConfig struct {
+	CAFile             string `mapstructure:"ca_file,omitempty"`
+	CertFile           string `mapstructure:"cert_file,omitempty"`
+	KeyFile            string `mapstructure:"key_file,omitempty"`
+	MinVersion         string `mapstructure:"min_version,omitempty"` // 1.0, 1.1, 1.2 or 1.3
+	ServerName         string `mapstructure:"server_name,omitempty"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify,omitempty"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion returns the TLS version of a min_version setting.
+func ParseTLSVersion(version string) (uint16, error) {
+	parsed, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("unsupported min_version %s", version)
+	}
+	return parsed, nil
+}
+
+// BuildTLSConfig returns the tls.Config described by conf, or nil if conf leaves the defaults untouched.
+func BuildTLSConfig(conf TLSConfig) (*tls.Config, error) {
+	if conf == (TLSConfig{}) {
+		return nil, nil
+	}
+
+	tlsConf := &tls.Config{
+		ServerName:         conf.ServerName,
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+	}
+
+	if conf.MinVersion != "" {
+		version, err := ParseTLSVersion(conf.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.MinVersion = version
+	}
+
+	if conf.CAFile != "" {
+		caPEM, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in ca_file %s", conf.CAFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+
+	if (conf.CertFile == "") != (conf.KeyFile == "") {
+		return nil, fmt.Errorf("cert_file and key_file must be set together")
+	}
+	if conf.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+
+	if conf.InsecureSkipVerify {
+		logrus.Warn("TLS certificate verification is DISABLED (insecure_skip_verify), " +
+			"connections are open to man-in-the-middle attacks and must never be used in production")
+	}
+	return tlsConf, nil
+}
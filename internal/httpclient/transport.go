@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"golang.org/x/net/http/httpproxy"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportConfig tunes the connections of a client.
+type TransportConfig struct {
+	ConnectTimeout        string `mapstructure:"connect_timeout,omitempty"`
+	TLSHandshakeTimeout   string `mapstructure:"tls_handshake_timeout,omitempty"`
+	ResponseHeaderTimeout string `mapstructure:"response_header_timeout,omitempty"`
+	Timeout               string `mapstructure:"timeout,omitempty"` // total time of a request, including reading the response
+	HTTPProxy             string `mapstructure:"http_proxy,omitempty"`
+	HTTPSProxy            string `mapstructure:"https_proxy,omitempty"`
+	NoProxy               string `mapstructure:"no_proxy,omitempty"` // comma separated list of hosts, domains and CIDRs
+	MaxIdleConnsPerHost   int    `mapstructure:"max_idle_conns_per_host,omitempty"`
+	DisableHTTP2          bool   `mapstructure:"disable_http2,omitempty"`
+}
+
+// NewClient builds the client and transport shared by every request of a processor.
+func NewClient(conf TransportConfig, tlsConf TLSConfig) (*http.Client, *http.Transport, error) {
+	transport, err := NewTransport(conf, tlsConf)
+	if err != nil {
+		return nil, nil, err
+	}
+	timeout, err := ParseDuration(conf.Timeout, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timeout: %w", err)
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, transport, nil
+}
+
+// NewTransport builds the transport described by conf and tlsConf.
+func NewTransport(conf TransportConfig, tlsConf TLSConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsClientConf, err := BuildTLSConfig(tlsConf)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls config: %w", err)
+	}
+	if tlsClientConf != nil {
+		transport.TLSClientConfig = tlsClientConf
+	}
+
+	connectTimeout, err := ParseDuration(conf.ConnectTimeout, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connect_timeout: %w", err)
+	}
+	transport.DialContext = (&net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+
+	transport.TLSHandshakeTimeout, err = ParseDuration(conf.TLSHandshakeTimeout, transport.TLSHandshakeTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls_handshake_timeout: %w", err)
+	}
+	transport.ResponseHeaderTimeout, err = ParseDuration(conf.ResponseHeaderTimeout, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response_header_timeout: %w", err)
+	}
+
+	if conf.HTTPProxy != "" || conf.HTTPSProxy != "" || conf.NoProxy != "" {
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  conf.HTTPProxy,
+			HTTPSProxy: conf.HTTPSProxy,
+			NoProxy:    conf.NoProxy,
+		}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	if conf.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = conf.MaxIdleConnsPerHost
+	}
+
+	if conf.DisableHTTP2 {
+		transport.ForceAttemptHTTP2 = false
+		// a non-nil empty map prevents the transport from upgrading to HTTP/2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	return transport, nil
+}
+
+// ParseDuration parses value, e.g. 30s, or returns defaultValue if it is empty.
+func ParseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,58 @@
+package httpregistry
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// ErrNotParked is returned when no request waits under a correlation ID, either because it was
+// never parked, it was already answered, or its client stopped waiting.
+var ErrNotParked = errors.New("no request is parked under the correlation ID")
+
+// Response is written back to the client of a parked request.
+type Response struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+}
+
+// Registry holds the requests whose clients wait for a response written by a later processor,
+// keyed by their correlation ID. It is shared by every processor created by the same factory.
+type Registry struct {
+	mu     sync.Mutex
+	parked map[string]func(Response)
+}
+
+func New() *Registry {
+	return &Registry{
+		parked: make(map[string]func(Response)),
+	}
+}
+
+// Park registers respond under id until it is answered or released.
+func (r *Registry) Park(id string, respond func(Response)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parked[id] = respond
+}
+
+// Respond answers the request parked under id and removes it.
+func (r *Registry) Respond(id string, resp Response) error {
+	r.mu.Lock()
+	respond, ok := r.parked[id]
+	delete(r.parked, id)
+	r.mu.Unlock()
+	if !ok {
+		return ErrNotParked
+	}
+	respond(resp)
+	return nil
+}
+
+// Release removes the request parked under id without answering it.
+func (r *Registry) Release(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.parked, id)
+}
@@ -0,0 +1,104 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled JSONPath of the form $.field.nested[0]['quoted field'] that selects a single value.
+type Path struct {
+	raw      string
+	segments []segment
+}
+
+type segment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// IsPath reports whether s looks like a JSONPath rather than another kind of expression.
+func IsPath(s string) bool {
+	return s == "$" || strings.HasPrefix(s, "$.") || strings.HasPrefix(s, "$[")
+}
+
+// Compile parses path.
+func Compile(path string) (*Path, error) {
+	if !IsPath(path) {
+		return nil, fmt.Errorf("json path %q must start with $", path)
+	}
+	p := &Path{raw: path}
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty field name in json path %q", path)
+			}
+			p.segments = append(p.segments, segment{key: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in json path %q", path)
+			}
+			inner := rest[1:end]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				p.segments = append(p.segments, segment{key: inner[1 : len(inner)-1]})
+			} else {
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index %q in json path %q", inner, path)
+				}
+				p.segments = append(p.segments, segment{index: index, isIndex: true})
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in json path %q", rest[0], path)
+		}
+	}
+	return p, nil
+}
+
+// String returns the path as it was written.
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Get returns the value the path selects within doc, a value decoded by encoding/json.
+// Negative indexes count from the end of arrays.
+func (p *Path) Get(doc any) (any, bool) {
+	current := doc
+	for _, s := range p.segments {
+		if s.isIndex {
+			array, ok := current.([]any)
+			if !ok {
+				return nil, false
+			}
+			index := s.index
+			if index < 0 {
+				index += len(array)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, false
+			}
+			current = array[index]
+			continue
+		}
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = object[s.key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
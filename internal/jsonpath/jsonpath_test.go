@@ -0,0 +1,40 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPath_Get(t *testing.T) {
+	var doc any
+	assert.NoError(t, json.Unmarshal([]byte(`{"id": "abc", "items": [{"name": "first"}, {"name": "last"}], "a b": {"c": 1}}`), &doc))
+
+	tests := []struct {
+		path     string
+		expected any
+		found    bool
+	}{
+		{"$.id", "abc", true},
+		{"$.items[0].name", "first", true},
+		{"$.items[-1].name", "last", true},
+		{"$['a b'].c", float64(1), true},
+		{"$.items[2]", nil, false},
+		{"$.missing", nil, false},
+		{"$.id.nested", nil, false},
+	}
+	for _, test := range tests {
+		path, err := Compile(test.path)
+		assert.NoError(t, err, test.path)
+		value, found := path.Get(doc)
+		assert.Equal(t, test.found, found, test.path)
+		assert.Equal(t, test.expected, value, test.path)
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, path := range []string{"id", "$.", "$[abc]", "$[0", "$x"} {
+		_, err := Compile(path)
+		assert.Error(t, err, path)
+	}
+}
@@ -0,0 +1,51 @@
+package kafkaclient
+
+import (
+	"fmt"
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+// scramClient adapts the SCRAM client of xdg-go/scram (RFC 5802) to sarama. Usernames and passwords
+// are normalized with SASLprep (RFC 4013) before they are used. Channel binding (the -PLUS
+// mechanisms) is not supported, as Kafka does not offer it.
+type scramClient struct {
+	hash         scram.HashGeneratorFcn
+	nonce        scram.NonceGeneratorFcn // replaces the random client nonce if set
+	conversation *scram.ClientConversation
+}
+
+func newSCRAMClientGenerator(hash scram.HashGeneratorFcn) func() sarama.SCRAMClient {
+	return func() sarama.SCRAMClient {
+		return &scramClient{hash: hash}
+	}
+}
+
+var (
+	scramSHA256 = newSCRAMClientGenerator(scram.SHA256)
+	scramSHA512 = newSCRAMClientGenerator(scram.SHA512)
+)
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hash.NewClient(userName, password, authzID)
+	if err != nil {
+		return fmt.Errorf("failed to create SCRAM client: %w", err)
+	}
+	if c.nonce != nil {
+		client = client.WithNonceGenerator(c.nonce)
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	response, err := c.conversation.Step(challenge)
+	if err != nil {
+		return "", fmt.Errorf("SCRAM authentication failed: %w", err)
+	}
+	return response, nil
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
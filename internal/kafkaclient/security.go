@@ -0,0 +1,141 @@
+package kafkaclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/IBM/sarama"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	"strings"
+)
+
+// SecurityConfig holds the SASL and TLS settings of the connections to the brokers, shared by the
+// Kafka processors and embedded in their config with mapstructure's squash.
+type SecurityConfig struct {
+	SASL SASLConfig `mapstructure:"sasl,omitempty"`
+	TLS  TLSConfig  `mapstructure:"tls,omitempty"`
+}
+
+type SASLConfig struct {
+	Mechanism string `mapstructure:"mechanism"` // PLAIN, SCRAM-SHA-256, SCRAM-SHA-512 or OAUTHBEARER
+	Username  string `mapstructure:"username,omitempty"`
+	Password  string `mapstructure:"password,omitempty"`
+	// OAUTHBEARER either sends a static token or fetches one with the client credentials grant
+	Token        string            `mapstructure:"token,omitempty"`
+	TokenURL     string            `mapstructure:"token_url,omitempty"`
+	ClientID     string            `mapstructure:"client_id,omitempty"`
+	ClientSecret string            `mapstructure:"client_secret,omitempty"`
+	Scopes       []string          `mapstructure:"scopes,omitempty"`
+	Extensions   map[string]string `mapstructure:"extensions,omitempty"`
+}
+
+type TLSConfig struct {
+	Enabled              bool `mapstructure:"enabled,omitempty"`
+	httpclient.TLSConfig `mapstructure:",squash"`
+}
+
+// Apply enables the configured SASL mechanism and TLS on config.
+func (c SecurityConfig) Apply(config *sarama.Config) error {
+	err := c.applySASL(config)
+	if err != nil {
+		return fmt.Errorf("invalid sasl config: %w", err)
+	}
+	err = c.applyTLS(config)
+	if err != nil {
+		return fmt.Errorf("invalid tls config: %w", err)
+	}
+	return nil
+}
+
+func (c SecurityConfig) applySASL(config *sarama.Config) error {
+	conf := c.SASL
+	if conf.Mechanism == "" {
+		return nil
+	}
+	mechanism := sarama.SASLMechanism(strings.ToUpper(conf.Mechanism))
+	switch mechanism {
+	case sarama.SASLTypePlaintext, sarama.SASLTypeSCRAMSHA256, sarama.SASLTypeSCRAMSHA512:
+		if conf.Username == "" || conf.Password == "" {
+			return fmt.Errorf("username and password are required for %s", mechanism)
+		}
+		config.Net.SASL.User = conf.Username
+		config.Net.SASL.Password = conf.Password
+		if mechanism == sarama.SASLTypeSCRAMSHA256 {
+			config.Net.SASL.SCRAMClientGeneratorFunc = scramSHA256
+		} else if mechanism == sarama.SASLTypeSCRAMSHA512 {
+			config.Net.SASL.SCRAMClientGeneratorFunc = scramSHA512
+		}
+	case sarama.SASLTypeOAuth:
+		provider, err := newTokenProvider(conf)
+		if err != nil {
+			return err
+		}
+		config.Net.SASL.TokenProvider = provider
+	default:
+		return fmt.Errorf("unsupported mechanism %s", conf.Mechanism)
+	}
+	config.Net.SASL.Enable = true
+	config.Net.SASL.Mechanism = mechanism
+	config.Net.SASL.Handshake = true
+	return nil
+}
+
+func (c SecurityConfig) applyTLS(config *sarama.Config) error {
+	if !c.TLS.Enabled {
+		if c.TLS.TLSConfig != (httpclient.TLSConfig{}) {
+			return fmt.Errorf("enabled must be set to use the tls settings")
+		}
+		return nil
+	}
+	tlsConf, err := httpclient.BuildTLSConfig(c.TLS.TLSConfig)
+	if err != nil {
+		return err
+	}
+	if tlsConf == nil {
+		// TLS with the system roots
+		tlsConf = &tls.Config{}
+	}
+	config.Net.TLS.Enable = true
+	config.Net.TLS.Config = tlsConf
+	return nil
+}
+
+// tokenProvider supplies the OAUTHBEARER tokens, which the token source caches until they expire.
+type tokenProvider struct {
+	source     oauth2.TokenSource
+	extensions map[string]string
+}
+
+func newTokenProvider(conf SASLConfig) (*tokenProvider, error) {
+	provider := &tokenProvider{extensions: conf.Extensions}
+	switch {
+	case conf.Token != "" && conf.TokenURL != "":
+		return nil, fmt.Errorf("token and token_url cannot be set together")
+	case conf.Token != "":
+		provider.source = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: conf.Token})
+	case conf.TokenURL != "":
+		if conf.ClientID == "" {
+			return nil, fmt.Errorf("client_id is required with token_url")
+		}
+		credentials := &clientcredentials.Config{
+			ClientID:     conf.ClientID,
+			ClientSecret: conf.ClientSecret,
+			TokenURL:     conf.TokenURL,
+			Scopes:       conf.Scopes,
+		}
+		provider.source = credentials.TokenSource(context.Background())
+	default:
+		return nil, fmt.Errorf("token or token_url is required for %s", sarama.SASLTypeOAuth)
+	}
+	return provider, nil
+}
+
+func (p *tokenProvider) Token() (*sarama.AccessToken, error) {
+	token, err := p.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OAUTHBEARER token: %w", err)
+	}
+	return &sarama.AccessToken{Token: token.AccessToken, Extensions: p.extensions}, nil
+}
@@ -0,0 +1,126 @@
+package kafkaclient
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/stretchr/testify/assert"
+	"github.com/xdg-go/scram"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestSCRAMClient() *scramClient {
+	return &scramClient{hash: scram.SHA256, nonce: func() string { return "rOprNGfwEbeRWgbNEkqO" }}
+}
+
+// TestSCRAMClient follows the SCRAM-SHA-256 example of RFC 7677.
+func TestSCRAMClient(t *testing.T) {
+	client := newTestSCRAMClient()
+	err := client.Begin("user", "pencil", "")
+	assert.NoError(t, err)
+
+	response, err := client.Step("")
+	assert.NoError(t, err)
+	assert.Equal(t, "n,,n=user,r=rOprNGfwEbeRWgbNEkqO", response)
+	assert.False(t, client.Done())
+
+	response, err = client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.NoError(t, err)
+	assert.Equal(t, "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ=", response)
+
+	_, err = client.Step("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")
+	assert.NoError(t, err)
+	assert.True(t, client.Done())
+}
+
+func TestSCRAMClient_Server_Errors(t *testing.T) {
+	client := newTestSCRAMClient()
+	_ = client.Begin("user", "pencil", "")
+	_, _ = client.Step("")
+	_, err := client.Step("r=other-nonce,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	assert.ErrorContains(t, err, "server nonce did not extend client nonce")
+
+	client = newTestSCRAMClient()
+	_ = client.Begin("user", "pencil", "")
+	_, _ = client.Step("")
+	_, _ = client.Step("r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096")
+	_, err = client.Step("v=AAAA")
+	assert.ErrorContains(t, err, "server validation failed")
+}
+
+func TestSCRAMClient_SASLprep(t *testing.T) {
+	serverFirst := "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+	proof := func(password string) string {
+		client := newTestSCRAMClient()
+		err := client.Begin("user", password, "")
+		assert.NoError(t, err)
+		_, _ = client.Step("")
+		response, err := client.Step(serverFirst)
+		assert.NoError(t, err)
+		return response
+	}
+	// the soft hyphen is mapped to nothing, so both passwords are the same
+	assert.Equal(t, proof("pencil"), proof("pen\u00ADcil"))
+
+	// prohibited characters are rejected before anything is sent
+	err := newTestSCRAMClient().Begin("user", "pen\u0007cil", "")
+	assert.ErrorContains(t, err, "failed to create SCRAM client")
+}
+
+func TestSecurityConfig_Apply(t *testing.T) {
+	for _, mechanism := range []string{"PLAIN", "scram-sha-256", "SCRAM-SHA-512"} {
+		config := sarama.NewConfig()
+		err := SecurityConfig{SASL: SASLConfig{Mechanism: mechanism, Username: "user", Password: "pass"}}.Apply(config)
+		assert.NoError(t, err, mechanism)
+		assert.True(t, config.Net.SASL.Enable)
+		assert.NoError(t, config.Validate(), mechanism)
+	}
+
+	config := sarama.NewConfig()
+	err := SecurityConfig{SASL: SASLConfig{Mechanism: "SCRAM-SHA-256"}}.Apply(config)
+	assert.ErrorContains(t, err, "username and password are required")
+	err = SecurityConfig{SASL: SASLConfig{Mechanism: "GSSAPI"}}.Apply(config)
+	assert.ErrorContains(t, err, "unsupported mechanism")
+
+	config = sarama.NewConfig()
+	err = SecurityConfig{TLS: TLSConfig{Enabled: true, TLSConfig: httpclient.TLSConfig{ServerName: "kafka.internal"}}}.Apply(config)
+	assert.NoError(t, err)
+	assert.True(t, config.Net.TLS.Enable)
+	assert.Equal(t, "kafka.internal", config.Net.TLS.Config.ServerName)
+
+	err = SecurityConfig{TLS: TLSConfig{TLSConfig: httpclient.TLSConfig{ServerName: "kafka.internal"}}}.Apply(sarama.NewConfig())
+	assert.ErrorContains(t, err, "enabled must be set")
+}
+
+func TestSecurityConfig_OAuthBearer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		assert.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token": "fetched", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer server.Close()
+
+	config := sarama.NewConfig()
+	err := SecurityConfig{SASL: SASLConfig{
+		Mechanism:    "OAUTHBEARER",
+		TokenURL:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Extensions:   map[string]string{"logicalCluster": "lkc-1"},
+	}}.Apply(config)
+	assert.NoError(t, err)
+	assert.NoError(t, config.Validate())
+	token, err := config.Net.SASL.TokenProvider.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "fetched", token.Token)
+	assert.Equal(t, map[string]string{"logicalCluster": "lkc-1"}, token.Extensions)
+
+	config = sarama.NewConfig()
+	err = SecurityConfig{SASL: SASLConfig{Mechanism: "OAUTHBEARER", Token: "static"}}.Apply(config)
+	assert.NoError(t, err)
+	token, err = config.Net.SASL.TokenProvider.Token()
+	assert.NoError(t, err)
+	assert.Equal(t, "static", token.Token)
+}
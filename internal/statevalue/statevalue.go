@@ -0,0 +1,17 @@
+// Package statevalue reads the values processors keep in their state, which come back with other
+// types once the state was encoded as JSON.
+package statevalue
+
+// Int64 converts a number stored in the state to an int64. Numbers stored as int come back as
+// float64 from a JSON state.
+func Int64(value any) (int64, bool) {
+	switch value := value.(type) {
+	case int:
+		return int64(value), true
+	case int64:
+		return value, true
+	case float64:
+		return int64(value), true
+	}
+	return 0, false
+}
@@ -0,0 +1,275 @@
+package fetchhttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/interfaces/utils"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+)
+
+const defaultMaxRedirects = 10
+
+type FetchHTTP struct {
+	definitions.BaseProcessor
+	config      *config
+	compiled    *compiledConfig
+	auth        *httpclient.Authenticator
+	transport   *http.Transport
+	client      utils.HTTPClient
+	exprOptions []expr.Option
+}
+
+type config struct {
+	URL             string                     `mapstructure:"url"`
+	Method          string                     `mapstructure:"method,omitempty"`
+	ExtraHeaders    map[string]string          `mapstructure:"extra_headers,omitempty"`
+	Body            string                     `mapstructure:"body,omitempty"`
+	BodyFromContent bool                       `mapstructure:"body_from_content,omitempty"`
+	ContentType     string                     `mapstructure:"content_type,omitempty"`
+	MaxRedirects    *int                       `mapstructure:"max_redirects,omitempty"`
+	MaxResponseBody int64                      `mapstructure:"max_response_body,omitempty"` // in bytes, unlimited if not set
+	Auth            httpclient.AuthConfig      `mapstructure:"auth,omitempty"`
+	TLS             httpclient.TLSConfig       `mapstructure:"tls,omitempty"`
+	Transport       httpclient.TransportConfig `mapstructure:"transport,omitempty"`
+}
+
+// compiledConfig holds the expressions of config, compiled once in SetConfig.
+type compiledConfig struct {
+	url         *expression.Expression
+	method      *expression.Expression
+	body        *expression.Expression
+	contentType *expression.Expression
+	headers     []expression.Header
+}
+
+func NewFetchHTTP(exprOptions ...expr.Option) definitions.Processor {
+	return &FetchHTTP{
+		exprOptions: exprOptions,
+	}
+}
+
+func (*FetchHTTP) Name() string {
+	return "FetchHTTP"
+}
+
+func (f *FetchHTTP) SetConfig(conf map[string]interface{}) error {
+	f.config = &config{}
+	err := f.DecodeMap(conf, f.config)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to decode config")
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+	if f.config.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if f.config.Method == "" {
+		f.config.Method = http.MethodGet
+	}
+	if f.config.Body != "" && f.config.BodyFromContent {
+		return fmt.Errorf("body and body_from_content cannot be set together")
+	}
+	maxRedirects := defaultMaxRedirects
+	if f.config.MaxRedirects != nil {
+		maxRedirects = *f.config.MaxRedirects
+	}
+	if maxRedirects < 0 {
+		return fmt.Errorf("max_redirects must not be negative")
+	}
+
+	f.compiled, err = f.compileConfig()
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to compile config expressions")
+		return fmt.Errorf("failed to compile config expressions: %w", err)
+	}
+
+	client, transport, ctx, err := httpclient.Configure(f.client, f.transport, f.config.Transport, f.config.TLS, func(client *http.Client) {
+		client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				// the redirect that was not followed is handed to the flow as the response
+				return http.ErrUseLastResponse
+			}
+			return nil
+		}
+	})
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to create HTTP client")
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	f.client, f.transport = client, transport
+
+	f.auth, err = httpclient.NewAuthenticator(ctx, f.config.Auth, f.exprOptions...)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid auth config")
+		return fmt.Errorf("invalid auth config: %w", err)
+	}
+	return nil
+}
+
+func (f *FetchHTTP) compileConfig() (*compiledConfig, error) {
+	var err error
+	compiled := &compiledConfig{}
+	compiled.url, err = expression.Compile(f.config.URL, f.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	compiled.method, err = expression.Compile(f.config.Method, f.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("method: %w", err)
+	}
+	compiled.body, err = expression.Compile(f.config.Body, f.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	compiled.contentType, err = expression.Compile(f.config.ContentType, f.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("content_type: %w", err)
+	}
+	compiled.headers, err = expression.CompileHeaders(f.config.ExtraHeaders, f.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("extra_headers %w", err)
+	}
+	return compiled, nil
+}
+
+func (f *FetchHTTP) Close() error {
+	if f.transport != nil {
+		f.transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (f *FetchHTTP) Execute(
+	info *definitions.EngineFlowObject,
+	fileHandler definitions.ProcessorFileHandler,
+	log *logrus.Logger,
+) (*definitions.EngineFlowObject, error) {
+	req, err := f.generateRequest(info, fileHandler)
+	if err != nil {
+		log.WithError(err).Errorf("failed to create HTTP request")
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	err = f.auth.Apply(req, info)
+	if err != nil {
+		log.WithError(err).Errorf("failed to authenticate request")
+		return nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	log.Debugf("sending %s %s with headers %v", req.Method, f.auth.RedactURL(req.URL), f.auth.RedactHeaders(req.Header))
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = f.auth.RedactURL(req.URL)
+		}
+		log.WithError(err).Errorf("failed to send HTTP request")
+		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	location := resp.Header.Get("Location")
+	redirected := resp.StatusCode >= 300 && resp.StatusCode < 400 && location != ""
+	if redirected {
+		log.Infof("max_redirects reached, not following the redirect to %s", location)
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Errorf("received non-2xx response: %s, body: %s", resp.Status, httpclient.ErrorBody(resp.Body))
+		return nil, fmt.Errorf("received non-2xx response: %s", resp.Status)
+	}
+	log.Debugf("Response status: %s", resp.Status)
+
+	writer, err := fileHandler.Write()
+	if err != nil {
+		log.WithError(err).Errorf("failed to write response to file")
+		return nil, fmt.Errorf("failed to write response to file: %w", err)
+	}
+	written, err := io.Copy(writer, httpclient.LimitBody(resp.Body, f.config.MaxResponseBody))
+	if err != nil {
+		log.WithError(err).Errorf("failed to write response to file")
+		return nil, fmt.Errorf("failed to write response to file: %w", err)
+	}
+	err = httpclient.CheckBodySize(written, f.config.MaxResponseBody)
+	if err != nil {
+		log.WithError(err).Errorf("response body is too large")
+		return nil, err
+	}
+
+	info.Metadata["FetchHTTP.StatusCode"] = resp.StatusCode
+	info.Metadata["FetchHTTP.Headers"] = resp.Header
+	info.Metadata["FetchHTTP.ContentType"] = resp.Header.Get("Content-Type")
+	info.Metadata["FetchHTTP.ContentLength"] = written
+	finalURL := req.URL
+	if resp.Request != nil {
+		// the URL after following the redirects
+		finalURL = resp.Request.URL
+	}
+	info.Metadata["FetchHTTP.URL"] = f.auth.RedactURL(finalURL)
+	if redirected {
+		info.Metadata["FetchHTTP.Location"] = location
+	} else {
+		delete(info.Metadata, "FetchHTTP.Location")
+	}
+	return info, nil
+}
+
+func (f *FetchHTTP) generateRequest(
+	info *definitions.EngineFlowObject,
+	fileHandler definitions.ProcessorFileHandler,
+) (*http.Request, error) {
+	url, err := f.compiled.url.Evaluate(info.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate url: %w", err)
+	}
+	method, err := f.compiled.method.Evaluate(info.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate method: %w", err)
+	}
+
+	var body io.Reader
+	if f.config.BodyFromContent {
+		// the request is sent before the response replaces the contents, so they are buffered
+		reader, err := fileHandler.Read()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		var content bytes.Buffer
+		_, err = io.Copy(&content, reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		body = &content
+	} else if f.config.Body != "" {
+		value, err := f.compiled.body.Evaluate(info.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate body: %w", err)
+		}
+		body = strings.NewReader(value)
+	}
+
+	req, err := http.NewRequest(strings.ToUpper(method), url, body)
+	if err != nil {
+		return nil, err
+	}
+	for _, header := range f.compiled.headers {
+		key, value, err := header.Evaluate(info.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(key, value)
+	}
+	if body != nil && f.config.ContentType != "" {
+		contentType, err := f.compiled.contentType.Evaluate(info.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate content type: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
@@ -0,0 +1,174 @@
+package fetchhttp
+
+import (
+	"bytes"
+	"encoding/pem"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// MockEngineFileHandler is a mock implementation of EngineFileHandler for testing
+type MockEngineFileHandler struct {
+	reader io.Reader
+	writer *bytes.Buffer
+}
+
+func (m *MockEngineFileHandler) Read() (io.Reader, error) {
+	return m.reader, nil
+}
+
+func (m *MockEngineFileHandler) Write() (io.Writer, error) {
+	return m.writer, nil
+}
+
+func (m *MockEngineFileHandler) Close() {}
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/files/"+r.URL.Query().Get("id"), http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/files/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("X-Version", "3")
+		_, _ = w.Write([]byte("a,b\n1,2\n"))
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		_, _ = io.Copy(w, r.Body)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFetchHTTP_Redirect(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	f := NewFetchHTTP().(*FetchHTTP)
+	err := f.SetConfig(map[string]interface{}{
+		"url":  server.URL + "/old?id=${FileID}",
+		"auth": map[string]interface{}{"type": "bearer", "token": "secret"},
+	})
+	assert.NoError(t, err)
+
+	fileHandler := &MockEngineFileHandler{writer: new(bytes.Buffer)}
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{"FileID": "42"}}
+	_, err = f.Execute(info, fileHandler, logrus.New())
+	assert.NoError(t, err)
+
+	assert.Equal(t, "a,b\n1,2\n", fileHandler.writer.String())
+	assert.Equal(t, 200, info.Metadata["FetchHTTP.StatusCode"])
+	assert.Equal(t, "text/csv", info.Metadata["FetchHTTP.ContentType"])
+	assert.EqualValues(t, 8, info.Metadata["FetchHTTP.ContentLength"])
+	assert.Equal(t, "3", info.Metadata["FetchHTTP.Headers"].(http.Header).Get("X-Version"))
+	assert.Equal(t, server.URL+"/files/42", info.Metadata["FetchHTTP.URL"])
+}
+
+func TestFetchHTTP_Max_Redirects(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	for _, maxRedirects := range []int{0, 3} {
+		f := NewFetchHTTP().(*FetchHTTP)
+		err := f.SetConfig(map[string]interface{}{
+			"url":           server.URL + "/loop",
+			"max_redirects": maxRedirects,
+		})
+		assert.NoError(t, err)
+
+		// the redirect past the limit is the response
+		fileHandler := &MockEngineFileHandler{writer: new(bytes.Buffer)}
+		info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{}}
+		_, err = f.Execute(info, fileHandler, logrus.New())
+		assert.NoError(t, err, maxRedirects)
+		assert.Equal(t, http.StatusFound, info.Metadata["FetchHTTP.StatusCode"], maxRedirects)
+		assert.Equal(t, "/loop", info.Metadata["FetchHTTP.Location"], maxRedirects)
+		assert.Equal(t, server.URL+"/loop", info.Metadata["FetchHTTP.URL"], maxRedirects)
+	}
+}
+
+func TestFetchHTTP_TLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("secure"))
+	}))
+	defer server.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+	assert.NoError(t, err)
+
+	// the system roots do not trust the test server
+	f := NewFetchHTTP().(*FetchHTTP)
+	err = f.SetConfig(map[string]interface{}{"url": server.URL})
+	assert.NoError(t, err)
+	_, err = f.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{}}, &MockEngineFileHandler{writer: new(bytes.Buffer)}, logrus.New())
+	assert.ErrorContains(t, err, "certificate")
+
+	err = f.SetConfig(map[string]interface{}{
+		"url":       server.URL,
+		"tls":       map[string]interface{}{"ca_file": caFile, "min_version": "1.2"},
+		"transport": map[string]interface{}{"timeout": "5s", "disable_http2": true},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 5*time.Second, f.client.(*http.Client).Timeout)
+	fileHandler := &MockEngineFileHandler{writer: new(bytes.Buffer)}
+	_, err = f.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{}}, fileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "secure", fileHandler.writer.String())
+
+	err = f.SetConfig(map[string]interface{}{
+		"url": server.URL,
+		"tls": map[string]interface{}{"ca_file": filepath.Join(t.TempDir(), "missing.pem")},
+	})
+	assert.Error(t, err)
+}
+
+func TestFetchHTTP_Body(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	f := NewFetchHTTP().(*FetchHTTP)
+	err := f.SetConfig(map[string]interface{}{
+		"url":               server.URL + "/echo",
+		"method":            "post",
+		"body_from_content": true,
+		"content_type":      "application/json",
+		"max_response_body": 64,
+	})
+	assert.NoError(t, err)
+
+	fileHandler := &MockEngineFileHandler{
+		reader: bytes.NewBufferString(`{"query": "status"}`),
+		writer: new(bytes.Buffer),
+	}
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{}}
+	_, err = f.Execute(info, fileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, `{"query": "status"}`, fileHandler.writer.String())
+	assert.Equal(t, "application/json", info.Metadata["FetchHTTP.ContentType"])
+
+	err = f.SetConfig(map[string]interface{}{
+		"url":               server.URL + "/echo",
+		"method":            "POST",
+		"body":              "${Payload}",
+		"max_response_body": 4,
+	})
+	assert.NoError(t, err)
+	_, err = f.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{"Payload": "too long"}},
+		&MockEngineFileHandler{writer: new(bytes.Buffer)}, logrus.New())
+	assert.ErrorContains(t, err, "exceeds max_response_body")
+}
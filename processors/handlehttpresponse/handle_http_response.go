@@ -0,0 +1,159 @@
+package handlehttpresponse
+
+import (
+	"errors"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpregistry"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const correlationIDKey = "HandleHTTPRequest.CorrelationID"
+
+// HandleHTTPResponse writes the contents of the flow file back to the client of the request
+// parked by HandleHTTPRequest.
+type HandleHTTPResponse struct {
+	definitions.BaseProcessor
+	config      *config
+	compiled    *compiledConfig
+	registry    *httpregistry.Registry
+	exprOptions []expr.Option
+}
+
+type config struct {
+	CorrelationID string            `mapstructure:"correlation_id,omitempty"` // the HandleHTTPRequest.CorrelationID metadata if not set
+	StatusCode    string            `mapstructure:"status_code,omitempty"`
+	Headers       map[string]string `mapstructure:"headers,omitempty"`
+}
+
+// compiledConfig holds the expressions of config, compiled once in SetConfig.
+type compiledConfig struct {
+	correlationID *expression.Expression
+	statusCode    *expression.Expression
+	headers       []expression.Header
+}
+
+func NewHandleHTTPResponse(registry *httpregistry.Registry, exprOptions ...expr.Option) definitions.Processor {
+	return &HandleHTTPResponse{
+		registry:    registry,
+		exprOptions: exprOptions,
+	}
+}
+
+func (*HandleHTTPResponse) Name() string {
+	return "HandleHTTPResponse"
+}
+
+func (h *HandleHTTPResponse) SetConfig(conf map[string]interface{}) error {
+	h.config = &config{}
+	err := h.DecodeMap(conf, h.config)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to decode config")
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+	if h.config.StatusCode == "" {
+		h.config.StatusCode = strconv.Itoa(http.StatusOK)
+	}
+
+	h.compiled, err = h.compileConfig()
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to compile config expressions")
+		return fmt.Errorf("failed to compile config expressions: %w", err)
+	}
+	return nil
+}
+
+func (h *HandleHTTPResponse) compileConfig() (*compiledConfig, error) {
+	var err error
+	compiled := &compiledConfig{}
+	if h.config.CorrelationID != "" {
+		compiled.correlationID, err = expression.Compile(h.config.CorrelationID, h.exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("correlation_id: %w", err)
+		}
+	}
+	compiled.statusCode, err = expression.Compile(h.config.StatusCode, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("status_code: %w", err)
+	}
+	compiled.headers, err = expression.CompileHeaders(h.config.Headers, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("headers %w", err)
+	}
+	return compiled, nil
+}
+
+func (h *HandleHTTPResponse) Close() error {
+	return nil
+}
+
+func (h *HandleHTTPResponse) Execute(
+	info *definitions.EngineFlowObject,
+	fileHandler definitions.ProcessorFileHandler,
+	log *logrus.Logger,
+) (*definitions.EngineFlowObject, error) {
+	resp, correlationID, err := h.generateResponse(info, fileHandler)
+	if err != nil {
+		log.WithError(err).Errorf("failed to create HTTP response")
+		return nil, fmt.Errorf("failed to create HTTP response: %w", err)
+	}
+
+	err = h.registry.Respond(correlationID, resp)
+	if errors.Is(err, httpregistry.ErrNotParked) {
+		log.Errorf("no request is waiting for correlation ID %s, it may have timed out", correlationID)
+		return nil, fmt.Errorf("no request is waiting for correlation ID %s: %w", correlationID, err)
+	}
+	log.Debugf("responded %d to the request of correlation ID %s", resp.StatusCode, correlationID)
+	return info, nil
+}
+
+func (h *HandleHTTPResponse) generateResponse(
+	info *definitions.EngineFlowObject,
+	fileHandler definitions.ProcessorFileHandler,
+) (httpregistry.Response, string, error) {
+	resp := httpregistry.Response{Headers: make(http.Header)}
+	var correlationID string
+	var err error
+	if h.compiled.correlationID != nil {
+		correlationID, err = h.compiled.correlationID.Evaluate(info.Metadata)
+		if err != nil {
+			return resp, "", fmt.Errorf("failed to evaluate correlation_id: %w", err)
+		}
+	} else {
+		correlationID, _ = info.Metadata[correlationIDKey].(string)
+	}
+	if correlationID == "" {
+		return resp, "", fmt.Errorf("correlation ID is empty")
+	}
+
+	statusCode, err := h.compiled.statusCode.Evaluate(info.Metadata)
+	if err != nil {
+		return resp, "", fmt.Errorf("failed to evaluate status_code: %w", err)
+	}
+	resp.StatusCode, err = strconv.Atoi(statusCode)
+	if err != nil || resp.StatusCode < 100 || resp.StatusCode > 999 {
+		return resp, "", fmt.Errorf("invalid status code %q", statusCode)
+	}
+	for _, header := range h.compiled.headers {
+		key, value, err := header.Evaluate(info.Metadata)
+		if err != nil {
+			return resp, "", err
+		}
+		resp.Headers.Set(key, value)
+	}
+
+	reader, err := fileHandler.Read()
+	if err != nil {
+		return resp, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	resp.Body, err = io.ReadAll(reader)
+	if err != nil {
+		return resp, "", fmt.Errorf("failed to read file: %w", err)
+	}
+	return resp, correlationID, nil
+}
@@ -0,0 +1,113 @@
+package handlehttpresponse
+
+import (
+	"bytes"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpregistry"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// MockEngineFileHandler is a mock implementation of EngineFileHandler for testing
+type MockEngineFileHandler struct {
+	contents string
+}
+
+func (m *MockEngineFileHandler) Read() (io.Reader, error) {
+	return bytes.NewBufferString(m.contents), nil
+}
+
+func (m *MockEngineFileHandler) Write() (io.Writer, error) {
+	return new(bytes.Buffer), nil
+}
+
+func (m *MockEngineFileHandler) Close() {}
+
+// park registers a request under id and returns the responses it receives.
+func park(registry *httpregistry.Registry, id string) *[]httpregistry.Response {
+	var responses []httpregistry.Response
+	registry.Park(id, func(resp httpregistry.Response) {
+		responses = append(responses, resp)
+	})
+	return &responses
+}
+
+func TestHandleHTTPResponse_Respond(t *testing.T) {
+	registry := httpregistry.New()
+	h := NewHandleHTTPResponse(registry)
+	err := h.SetConfig(map[string]interface{}{
+		"status_code": "${Status}",
+		"headers": map[string]interface{}{
+			"Content-Type": "application/json",
+			"X-Order-ID":   "${OrderID}",
+		},
+	})
+	assert.NoError(t, err)
+
+	responses := park(registry, "request-1")
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{
+		"HandleHTTPRequest.CorrelationID": "request-1",
+		"Status":                          "201",
+		"OrderID":                         "42",
+	}}
+	_, err = h.Execute(info, &MockEngineFileHandler{contents: `{"created": true}`}, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []httpregistry.Response{{
+		StatusCode: http.StatusCreated,
+		Headers:    http.Header{"Content-Type": {"application/json"}, "X-Order-Id": {"42"}},
+		Body:       []byte(`{"created": true}`),
+	}}, *responses)
+
+	// a second reply to the same request
+	_, err = h.Execute(info, &MockEngineFileHandler{contents: "again"}, logrus.New())
+	assert.ErrorIs(t, err, httpregistry.ErrNotParked)
+	assert.Len(t, *responses, 1)
+}
+
+func TestHandleHTTPResponse_Correlation_ID(t *testing.T) {
+	registry := httpregistry.New()
+	h := NewHandleHTTPResponse(registry)
+	err := h.SetConfig(map[string]interface{}{"correlation_id": "${RequestID}"})
+	assert.NoError(t, err)
+
+	responses := park(registry, "request-1")
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{"RequestID": "request-1"}}
+	_, err = h.Execute(info, &MockEngineFileHandler{}, logrus.New())
+	assert.NoError(t, err)
+	assert.Len(t, *responses, 1)
+	assert.Equal(t, http.StatusOK, (*responses)[0].StatusCode)
+
+	// never parked
+	info = &definitions.EngineFlowObject{Metadata: map[string]interface{}{"RequestID": "unknown"}}
+	_, err = h.Execute(info, &MockEngineFileHandler{}, logrus.New())
+	assert.ErrorIs(t, err, httpregistry.ErrNotParked)
+
+	// released once its client stopped waiting
+	responses = park(registry, "request-2")
+	registry.Release("request-2")
+	info = &definitions.EngineFlowObject{Metadata: map[string]interface{}{"RequestID": "request-2"}}
+	_, err = h.Execute(info, &MockEngineFileHandler{}, logrus.New())
+	assert.ErrorIs(t, err, httpregistry.ErrNotParked)
+	assert.Empty(t, *responses)
+}
+
+func TestHandleHTTPResponse_Invalid(t *testing.T) {
+	registry := httpregistry.New()
+	h := NewHandleHTTPResponse(registry)
+	err := h.SetConfig(map[string]interface{}{"status_code": "${Status}"})
+	assert.NoError(t, err)
+
+	for name, metadata := range map[string]map[string]interface{}{
+		"missing correlation ID": {"Status": "200"},
+		"invalid status code":    {"HandleHTTPRequest.CorrelationID": "request-1", "Status": "OK"},
+		"status code too low":    {"HandleHTTPRequest.CorrelationID": "request-1", "Status": "42"},
+	} {
+		responses := park(registry, "request-1")
+		_, err = h.Execute(&definitions.EngineFlowObject{Metadata: metadata}, &MockEngineFileHandler{}, logrus.New())
+		assert.Error(t, err, name)
+		assert.Empty(t, *responses, name)
+	}
+}
@@ -1,6 +1,7 @@
 package io
 
 import (
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
 	"github.com/sirupsen/logrus"
 	"io"
@@ -9,7 +10,8 @@ import (
 
 type ReadFile struct {
 	definitions.BaseProcessor
-	config *readFileConfig
+	config      *readFileConfig
+	exprOptions []expr.Option
 }
 
 type readFileConfig struct {
@@ -17,8 +19,10 @@ type readFileConfig struct {
 	RemoveSource bool   `mapstructure:"remove_source"`
 }
 
-func NewReadFile() definitions.Processor {
-	return &ReadFile{}
+func NewReadFile(exprOptions ...expr.Option) definitions.Processor {
+	return &ReadFile{
+		exprOptions: exprOptions,
+	}
 }
 
 func (r *ReadFile) SetConfig(conf map[string]interface{}) error {
@@ -46,7 +50,7 @@ func (r *ReadFile) Execute(
 	}
 
 	log.Debugf("evaluating expression %s", r.config.Input)
-	inputPath, err := info.EvaluateExpression(r.config.Input)
+	inputPath, err := info.EvaluateExpression(r.config.Input, r.exprOptions...)
 	if err != nil {
 		return nil, err
 	}
@@ -1,7 +1,9 @@
 package io
 
 import (
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
 	"github.com/sirupsen/logrus"
 	"io"
 	"os"
@@ -10,20 +12,29 @@ import (
 
 type WriteFile struct {
 	definitions.BaseProcessor
-	config *writeFileHandlerConfig
+	config      *writeFileHandlerConfig
+	output      *expression.Expression
+	exprOptions []expr.Option
 }
 
 type writeFileHandlerConfig struct {
 	Output string `mapstructure:"output"`
 }
 
-func NewWriteFile() definitions.Processor {
-	return &WriteFile{}
+func NewWriteFile(exprOptions ...expr.Option) definitions.Processor {
+	return &WriteFile{
+		exprOptions: exprOptions,
+	}
 }
 
 func (w *WriteFile) SetConfig(conf map[string]interface{}) error {
 	w.config = &writeFileHandlerConfig{}
-	return w.DecodeMap(conf, &w.config)
+	err := w.DecodeMap(conf, &w.config)
+	if err != nil {
+		return err
+	}
+	w.output, err = expression.Compile(w.config.Output, w.exprOptions...)
+	return err
 }
 
 func (w *WriteFile) Name() string {
@@ -47,7 +58,7 @@ func (w *WriteFile) Execute(
 
 	}
 	log.Debugf("evaluating expression %s", w.config.Output)
-	outputPath, err := info.EvaluateExpression(w.config.Output)
+	outputPath, err := w.output.Evaluate(info.Metadata)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,104 @@
+package kafka
+
+import (
+	"fmt"
+	"github.com/IBM/sarama"
+	"strings"
+	"time"
+)
+
+var compressionCodecs = map[string]sarama.CompressionCodec{
+	"none":   sarama.CompressionNone,
+	"gzip":   sarama.CompressionGZIP,
+	"snappy": sarama.CompressionSnappy,
+	"lz4":    sarama.CompressionLZ4,
+	"zstd":   sarama.CompressionZSTD,
+}
+
+// producerTuningConfig holds the settings of the producer that trade latency for throughput and
+// delivery guarantees. Unset values keep sarama's defaults.
+type producerTuningConfig struct {
+	Version          string `mapstructure:"kafka_version,omitempty"`
+	Compression      string `mapstructure:"compression,omitempty"` // none, gzip, snappy, lz4 or zstd
+	CompressionLevel *int   `mapstructure:"compression_level,omitempty"`
+	Idempotent       bool   `mapstructure:"idempotent,omitempty"`
+	MaxMessageBytes  int    `mapstructure:"max_message_bytes,omitempty"`
+	Linger           string `mapstructure:"linger,omitempty"`         // how long messages wait to be batched
+	BatchSize        int    `mapstructure:"batch_size,omitempty"`     // bytes that flush a batch
+	BatchMessages    int    `mapstructure:"batch_messages,omitempty"` // messages that flush a batch
+	Retries          *int   `mapstructure:"retries,omitempty"`
+	RetryBackoff     string `mapstructure:"retry_backoff,omitempty"`
+	TransactionalID  string `mapstructure:"transactional_id,omitempty"` // publishes every flow file in its own transaction if set
+}
+
+// apply sets the tuning of conf on config and validates the result.
+func (conf producerTuningConfig) apply(config *sarama.Config) error {
+	var err error
+	if conf.Version != "" {
+		config.Version, err = sarama.ParseKafkaVersion(conf.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	if conf.Compression != "" {
+		codec, ok := compressionCodecs[strings.ToLower(conf.Compression)]
+		if !ok {
+			return fmt.Errorf("invalid compression value: %s", conf.Compression)
+		}
+		config.Producer.Compression = codec
+	}
+	if conf.CompressionLevel != nil {
+		if config.Producer.Compression == sarama.CompressionNone {
+			return fmt.Errorf("compression_level requires a compression codec")
+		}
+		config.Producer.CompressionLevel = *conf.CompressionLevel
+	}
+
+	if conf.MaxMessageBytes < 0 || conf.BatchSize < 0 || conf.BatchMessages < 0 {
+		return fmt.Errorf("max_message_bytes, batch_size and batch_messages must not be negative")
+	}
+	if conf.MaxMessageBytes > 0 {
+		config.Producer.MaxMessageBytes = conf.MaxMessageBytes
+	}
+	// each Execute waits for its message to be acknowledged, so batches only fill up with the
+	// messages of concurrent sessions. Without a linger, a batch threshold that those never reach
+	// would hold the message of a single session forever.
+	if conf.Linger != "" {
+		config.Producer.Flush.Frequency, err = time.ParseDuration(conf.Linger)
+		if err != nil {
+			return fmt.Errorf("invalid linger value: %w", err)
+		}
+	}
+	if (conf.BatchSize > 0 || conf.BatchMessages > 0) && config.Producer.Flush.Frequency <= 0 {
+		return fmt.Errorf("batch_size and batch_messages require linger")
+	}
+	config.Producer.Flush.Bytes = conf.BatchSize
+	config.Producer.Flush.Messages = conf.BatchMessages
+
+	if conf.Retries != nil {
+		config.Producer.Retry.Max = *conf.Retries
+	}
+	if conf.RetryBackoff != "" {
+		config.Producer.Retry.Backoff, err = time.ParseDuration(conf.RetryBackoff)
+		if err != nil {
+			return fmt.Errorf("invalid retry_backoff value: %w", err)
+		}
+	}
+
+	if conf.TransactionalID != "" {
+		// transactions build on the sequence numbers of the idempotent producer
+		config.Producer.Transaction.ID = conf.TransactionalID
+		conf.Idempotent = true
+	}
+	if conf.Idempotent {
+		if config.Producer.RequiredAcks != sarama.WaitForAll {
+			return fmt.Errorf("idempotent producer requires acks to be all")
+		}
+		config.Producer.Idempotent = true
+		// sarama only keeps the order of the batches of an idempotent producer with a single in-flight request
+		config.Net.MaxOpenRequests = 1
+	}
+
+	return config.Validate()
+}
@@ -4,28 +4,64 @@ import (
 	"context"
 	"fmt"
 	"github.com/IBM/sarama"
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"github.com/go-streamline/standard-processors-bundle/internal/kafkaclient"
 	"github.com/sirupsen/logrus"
 	"io"
+	"strconv"
 	"strings"
+	"sync"
+)
+
+type partitionerType string
+
+const (
+	partitionerHash       partitionerType = "hash"
+	partitionerRandom     partitionerType = "random"
+	partitionerRoundRobin partitionerType = "round_robin"
+	partitionerManual     partitionerType = "manual"
 )
 
 type PublishKafka struct {
 	definitions.BaseProcessor
-	ctx      context.Context
-	config   *publishKafkaConfig
-	producer sarama.SyncProducer
+	ctx         context.Context
+	config      *publishKafkaConfig
+	compiled    *compiledConfig
+	producer    sarama.SyncProducer
+	txnMu       sync.Mutex // a transactional producer runs a single transaction at a time
+	newProducer func(brokers []string, config *sarama.Config) (sarama.SyncProducer, error)
+	exprOptions []expr.Option
 }
 
 type publishKafkaConfig struct {
-	BootstrapServers string `mapstructure:"bootstrap_servers"` // comma separated list of brokers
-	Topic            string `mapstructure:"topic"`
-	Acks             string `mapstructure:"acks"` // all, none, or local
+	BootstrapServers string            `mapstructure:"bootstrap_servers"` // comma separated list of brokers
+	Topic            string            `mapstructure:"topic"`
+	Acks             string            `mapstructure:"acks"` // all, none, or local
+	Key              string            `mapstructure:"key,omitempty"`
+	Headers          map[string]string `mapstructure:"headers,omitempty"`
+	MetadataHeaders  []string          `mapstructure:"metadata_headers,omitempty"` // metadata keys sent as headers of the same name
+	Partitioner      partitionerType   `mapstructure:"partitioner,omitempty"`      // hash, random, round_robin or manual
+	Partition        string            `mapstructure:"partition,omitempty"`        // for the manual partitioner
+
+	producerTuningConfig       `mapstructure:",squash"`
+	kafkaclient.SecurityConfig `mapstructure:",squash"`
 }
 
-func NewPublishKafka() definitions.Processor {
+// compiledConfig holds the expressions of publishKafkaConfig, compiled once in SetConfig.
+type compiledConfig struct {
+	topic     *expression.Expression
+	key       *expression.Expression
+	partition *expression.Expression
+	headers   []expression.Header
+}
+
+func NewPublishKafka(exprOptions ...expr.Option) definitions.Processor {
 	return &PublishKafka{
-		ctx: context.Background(),
+		ctx:         context.Background(),
+		newProducer: sarama.NewSyncProducer,
+		exprOptions: exprOptions,
 	}
 }
 
@@ -41,6 +77,9 @@ func (p *PublishKafka) SetConfig(config map[string]interface{}) error {
 		return err
 	}
 	p.config = conf
+	if p.config.Topic == "" {
+		return fmt.Errorf("topic is required")
+	}
 
 	// Configure producer
 	producerConfig := sarama.NewConfig()
@@ -55,10 +94,53 @@ func (p *PublishKafka) SetConfig(config map[string]interface{}) error {
 		return fmt.Errorf("invalid acks value: %s", p.config.Acks)
 	}
 
+	switch p.config.Partitioner {
+	case "", partitionerHash:
+		producerConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	case partitionerRandom:
+		producerConfig.Producer.Partitioner = sarama.NewRandomPartitioner
+	case partitionerRoundRobin:
+		producerConfig.Producer.Partitioner = sarama.NewRoundRobinPartitioner
+	case partitionerManual:
+		if p.config.Partition == "" {
+			return fmt.Errorf("partition is required for the manual partitioner")
+		}
+		producerConfig.Producer.Partitioner = sarama.NewManualPartitioner
+	default:
+		return fmt.Errorf("invalid partitioner value: %s", p.config.Partitioner)
+	}
+	if p.config.Partition != "" && p.config.Partitioner != partitionerManual {
+		return fmt.Errorf("partition is only supported by the manual partitioner")
+	}
+
 	producerConfig.Producer.Return.Successes = true
 
+	err = p.config.SecurityConfig.Apply(producerConfig)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid security config")
+		return err
+	}
+
+	err = p.config.producerTuningConfig.apply(producerConfig)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid producer config")
+		return fmt.Errorf("invalid producer config: %w", err)
+	}
+
+	p.compiled, err = p.compileConfig()
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to compile config expressions")
+		return fmt.Errorf("failed to compile config expressions: %w", err)
+	}
+
+	if p.producer != nil {
+		err = p.producer.Close()
+		if err != nil {
+			logrus.WithError(err).Warnf("failed to close the previous Kafka producer")
+		}
+	}
 	brokers := strings.Split(p.config.BootstrapServers, ",")
-	p.producer, err = sarama.NewSyncProducer(brokers, producerConfig)
+	p.producer, err = p.newProducer(brokers, producerConfig)
 	if err != nil {
 		logrus.WithError(err).Errorf("failed to create Kafka producer")
 		return err
@@ -67,6 +149,32 @@ func (p *PublishKafka) SetConfig(config map[string]interface{}) error {
 	return nil
 }
 
+func (p *PublishKafka) compileConfig() (*compiledConfig, error) {
+	var err error
+	compiled := &compiledConfig{}
+	compiled.topic, err = expression.Compile(p.config.Topic, p.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("topic: %w", err)
+	}
+	if p.config.Key != "" {
+		compiled.key, err = expression.Compile(p.config.Key, p.exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("key: %w", err)
+		}
+	}
+	if p.config.Partition != "" {
+		compiled.partition, err = expression.Compile(p.config.Partition, p.exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("partition: %w", err)
+		}
+	}
+	compiled.headers, err = expression.CompileHeaders(p.config.Headers, p.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("headers %w", err)
+	}
+	return compiled, nil
+}
+
 func (p *PublishKafka) Close() error {
 	if p.producer != nil {
 		return p.producer.Close()
@@ -88,25 +196,119 @@ func (p *PublishKafka) Execute(info *definitions.EngineFlowObject, fileHandler d
 		return nil, err
 	}
 
-	message := &sarama.ProducerMessage{
-		Topic: p.config.Topic,
-		Value: sarama.ByteEncoder(data),
+	message, err := p.generateMessage(info)
+	if err != nil {
+		log.WithError(err).Errorf("failed to create Kafka message")
+		return nil, fmt.Errorf("failed to create Kafka message: %w", err)
 	}
+	message.Value = sarama.ByteEncoder(data)
 
-	partition, offset, err := p.producer.SendMessage(message)
+	partition, offset, err := p.send(log, message)
 	if err != nil {
-		log.WithError(err).Errorf("failed to publish message to topic %s", p.config.Topic)
+		log.WithError(err).Errorf("failed to publish message to topic %s", message.Topic)
 		return nil, err
 	}
 
-	log.Infof("Message published to topic %s, partition %d, offset %d", p.config.Topic, partition, offset)
+	log.Infof("Message published to topic %s, partition %d, offset %d", message.Topic, partition, offset)
 	log.Debug("completed PublishKafka execution")
 
 	return &definitions.EngineFlowObject{
 		Metadata: map[string]interface{}{
-			"PublishKafka.Topic":     p.config.Topic,
+			"PublishKafka.Topic":     message.Topic,
 			"PublishKafka.Partition": partition,
 			"PublishKafka.Offset":    offset,
 		},
 	}, nil
 }
+
+// send publishes message, in a transaction of its own if the producer is transactional. The
+// transaction is aborted if the message is not acknowledged, so consumers reading with read_committed
+// never see it.
+func (p *PublishKafka) send(log *logrus.Logger, message *sarama.ProducerMessage) (int32, int64, error) {
+	if !p.producer.IsTransactional() {
+		return p.producer.SendMessage(message)
+	}
+
+	p.txnMu.Lock()
+	defer p.txnMu.Unlock()
+	err := p.producer.BeginTxn()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	partition, offset, err := p.producer.SendMessage(message)
+	if err != nil {
+		p.abort(log)
+		return 0, 0, err
+	}
+	err = p.producer.CommitTxn()
+	if err != nil {
+		p.abort(log)
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return partition, offset, nil
+}
+
+func (p *PublishKafka) abort(log *logrus.Logger) {
+	if p.producer.TxnStatus()&sarama.ProducerTxnFlagFatalError != 0 {
+		log.Errorf("Kafka producer is in a fatal transaction state and must be reconfigured")
+		return
+	}
+	err := p.producer.AbortTxn()
+	if err != nil {
+		log.WithError(err).Errorf("failed to abort transaction")
+	}
+}
+
+// generateMessage evaluates the topic, key, partition and headers of the message of info.
+func (p *PublishKafka) generateMessage(info *definitions.EngineFlowObject) (*sarama.ProducerMessage, error) {
+	topic, err := p.compiled.topic.Evaluate(info.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate topic: %w", err)
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("topic evaluated to an empty string")
+	}
+	message := &sarama.ProducerMessage{Topic: topic}
+
+	if p.compiled.key != nil {
+		key, err := p.compiled.key.Evaluate(info.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate key: %w", err)
+		}
+		message.Key = sarama.StringEncoder(key)
+	}
+
+	if p.compiled.partition != nil {
+		value, err := p.compiled.partition.Evaluate(info.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate partition: %w", err)
+		}
+		partition, err := strconv.ParseInt(value, 10, 32)
+		if err != nil || partition < 0 {
+			return nil, fmt.Errorf("invalid partition %q", value)
+		}
+		message.Partition = int32(partition)
+	}
+
+	for _, key := range p.config.MetadataHeaders {
+		value, ok := info.Metadata[key]
+		if !ok {
+			continue
+		}
+		message.Headers = append(message.Headers, sarama.RecordHeader{
+			Key:   []byte(key),
+			Value: []byte(fmt.Sprintf("%v", value)),
+		})
+	}
+	for _, header := range p.compiled.headers {
+		key, value, err := header.Evaluate(info.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		message.Headers = append(message.Headers, sarama.RecordHeader{
+			Key:   []byte(key),
+			Value: []byte(value),
+		})
+	}
+	return message, nil
+}
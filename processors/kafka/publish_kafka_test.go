@@ -0,0 +1,272 @@
+package kafka
+
+import (
+	"bytes"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+// MockEngineFileHandler is a mock implementation of EngineFileHandler for testing
+type MockEngineFileHandler struct {
+	reader io.Reader
+}
+
+func (m *MockEngineFileHandler) Read() (io.Reader, error) {
+	return m.reader, nil
+}
+
+func (m *MockEngineFileHandler) Write() (io.Writer, error) {
+	return new(bytes.Buffer), nil
+}
+
+func (m *MockEngineFileHandler) Close() {}
+
+// newTestPublishKafka configures a PublishKafka whose producer is a sarama mock.
+func newTestPublishKafka(t *testing.T, conf map[string]interface{}) (*PublishKafka, *mocks.SyncProducer) {
+	var producer *mocks.SyncProducer
+	p := NewPublishKafka().(*PublishKafka)
+	p.newProducer = func(_ []string, config *sarama.Config) (sarama.SyncProducer, error) {
+		producer = mocks.NewSyncProducer(t, config)
+		return producer, nil
+	}
+	conf["bootstrap_servers"] = "localhost:9092"
+	conf["acks"] = "all"
+	err := p.SetConfig(conf)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = p.Close() })
+	return p, producer
+}
+
+func headers(message *sarama.ProducerMessage) map[string]string {
+	values := make(map[string]string, len(message.Headers))
+	for _, header := range message.Headers {
+		values[string(header.Key)] = string(header.Value)
+	}
+	return values
+}
+
+func TestPublishKafka_Message(t *testing.T) {
+	p, producer := newTestPublishKafka(t, map[string]interface{}{
+		"topic":            "orders-${Region}",
+		"key":              "${CustomerID}",
+		"headers":          map[string]interface{}{"X-Source": "${Source}", "X-Static": "value"},
+		"metadata_headers": []string{"TraceID", "Missing"},
+	})
+
+	var sent *sarama.ProducerMessage
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(message *sarama.ProducerMessage) error {
+		sent = message
+		return nil
+	})
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{
+		"Region":     "eu",
+		"CustomerID": "42",
+		"Source":     "shop",
+		"TraceID":    7,
+	}}
+	result, err := p.Execute(info, &MockEngineFileHandler{reader: bytes.NewBufferString("payload")}, logrus.New())
+	assert.NoError(t, err)
+
+	assert.Equal(t, "orders-eu", sent.Topic)
+	assert.Equal(t, sarama.StringEncoder("42"), sent.Key)
+	assert.Equal(t, sarama.ByteEncoder("payload"), sent.Value)
+	assert.Equal(t, map[string]string{"X-Source": "shop", "X-Static": "value", "TraceID": "7"}, headers(sent))
+	assert.Equal(t, "orders-eu", result.Metadata["PublishKafka.Topic"])
+}
+
+func TestPublishKafka_Partitioner(t *testing.T) {
+	p, producer := newTestPublishKafka(t, map[string]interface{}{
+		"topic":       "orders",
+		"partitioner": "manual",
+		"partition":   "${Shard}",
+	})
+
+	var partition int32
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(message *sarama.ProducerMessage) error {
+		partition = message.Partition
+		return nil
+	})
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{"Shard": 3}}
+	_, err := p.Execute(info, &MockEngineFileHandler{reader: bytes.NewBufferString("payload")}, logrus.New())
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, partition)
+
+	info = &definitions.EngineFlowObject{Metadata: map[string]interface{}{"Shard": "first"}}
+	_, err = p.Execute(info, &MockEngineFileHandler{reader: bytes.NewBufferString("payload")}, logrus.New())
+	assert.ErrorContains(t, err, `invalid partition "first"`)
+
+	for partitioner, valid := range map[string]bool{"hash": true, "random": true, "round_robin": true, "sticky": false} {
+		err = p.SetConfig(map[string]interface{}{
+			"bootstrap_servers": "localhost:9092",
+			"topic":             "orders",
+			"acks":              "all",
+			"partitioner":       partitioner,
+		})
+		assert.Equal(t, valid, err == nil, partitioner)
+	}
+	err = p.SetConfig(map[string]interface{}{
+		"bootstrap_servers": "localhost:9092",
+		"topic":             "orders",
+		"acks":              "all",
+		"partitioner":       "manual",
+	})
+	assert.ErrorContains(t, err, "partition is required")
+}
+
+func TestPublishKafka_Security(t *testing.T) {
+	var config *sarama.Config
+	p := NewPublishKafka().(*PublishKafka)
+	p.newProducer = func(_ []string, c *sarama.Config) (sarama.SyncProducer, error) {
+		config = c
+		return mocks.NewSyncProducer(t, c), nil
+	}
+	err := p.SetConfig(map[string]interface{}{
+		"bootstrap_servers": "localhost:9092",
+		"topic":             "orders",
+		"acks":              "all",
+		"sasl": map[string]interface{}{
+			"mechanism": "SCRAM-SHA-512",
+			"username":  "user",
+			"password":  "pass",
+		},
+		"tls": map[string]interface{}{
+			"enabled":     true,
+			"server_name": "kafka.internal",
+		},
+	})
+	assert.NoError(t, err)
+	defer p.Close()
+
+	assert.True(t, config.Net.SASL.Enable)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512), config.Net.SASL.Mechanism)
+	assert.Equal(t, "user", config.Net.SASL.User)
+	assert.NotNil(t, config.Net.SASL.SCRAMClientGeneratorFunc)
+	assert.True(t, config.Net.TLS.Enable)
+	assert.Equal(t, "kafka.internal", config.Net.TLS.Config.ServerName)
+}
+
+func TestPublishKafka_Producer_Tuning(t *testing.T) {
+	var config *sarama.Config
+	p := NewPublishKafka().(*PublishKafka)
+	p.newProducer = func(_ []string, c *sarama.Config) (sarama.SyncProducer, error) {
+		config = c
+		return mocks.NewSyncProducer(t, c), nil
+	}
+	defer p.Close()
+	conf := func(tuning map[string]interface{}) map[string]interface{} {
+		conf := map[string]interface{}{
+			"bootstrap_servers": "localhost:9092",
+			"topic":             "orders",
+			"acks":              "all",
+		}
+		for key, value := range tuning {
+			conf[key] = value
+		}
+		return conf
+	}
+
+	err := p.SetConfig(conf(map[string]interface{}{
+		"kafka_version":     "3.6.0",
+		"compression":       "zstd",
+		"compression_level": 3,
+		"idempotent":        true,
+		"max_message_bytes": 2 << 20,
+		"linger":            "20ms",
+		"batch_size":        64 << 10,
+		"batch_messages":    500,
+		"retries":           10,
+		"retry_backoff":     "250ms",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, sarama.V3_6_0_0, config.Version)
+	assert.Equal(t, sarama.CompressionZSTD, config.Producer.Compression)
+	assert.Equal(t, 3, config.Producer.CompressionLevel)
+	assert.True(t, config.Producer.Idempotent)
+	assert.Equal(t, 1, config.Net.MaxOpenRequests)
+	assert.Equal(t, 2<<20, config.Producer.MaxMessageBytes)
+	assert.Equal(t, 20*time.Millisecond, config.Producer.Flush.Frequency)
+	assert.Equal(t, 64<<10, config.Producer.Flush.Bytes)
+	assert.Equal(t, 500, config.Producer.Flush.Messages)
+	assert.Equal(t, 10, config.Producer.Retry.Max)
+	assert.Equal(t, 250*time.Millisecond, config.Producer.Retry.Backoff)
+
+	for name, tuning := range map[string]map[string]interface{}{
+		"idempotent without acks all":   {"acks": "local", "idempotent": true},
+		"idempotent without retries":    {"idempotent": true, "retries": 0},
+		"zstd on an old version":        {"kafka_version": "1.0.0", "compression": "zstd"},
+		"unknown codec":                 {"compression": "brotli"},
+		"level without codec":           {"compression_level": 5},
+		"invalid linger":                {"linger": "soon"},
+		"batch_size without linger":     {"batch_size": 64 << 10},
+		"batch_messages without linger": {"batch_messages": 500},
+	} {
+		err = p.SetConfig(conf(tuning))
+		assert.Error(t, err, name)
+	}
+}
+
+// txnRecorder counts the transactions a SyncProducer mock commits and aborts.
+type txnRecorder struct {
+	*mocks.SyncProducer
+	committed, aborted int
+}
+
+func (r *txnRecorder) CommitTxn() error {
+	r.committed++
+	return r.SyncProducer.CommitTxn()
+}
+
+func (r *txnRecorder) AbortTxn() error {
+	r.aborted++
+	return r.SyncProducer.AbortTxn()
+}
+
+func TestPublishKafka_Transactional(t *testing.T) {
+	var config *sarama.Config
+	var producer *txnRecorder
+	p := NewPublishKafka().(*PublishKafka)
+	p.newProducer = func(_ []string, c *sarama.Config) (sarama.SyncProducer, error) {
+		config = c
+		producer = &txnRecorder{SyncProducer: mocks.NewSyncProducer(t, c)}
+		return producer, nil
+	}
+	defer p.Close()
+	err := p.SetConfig(map[string]interface{}{
+		"bootstrap_servers": "localhost:9092",
+		"topic":             "billing",
+		"acks":              "all",
+		"transactional_id":  "billing-publisher",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "billing-publisher", config.Producer.Transaction.ID)
+	assert.True(t, config.Producer.Idempotent)
+	assert.Equal(t, 1, config.Net.MaxOpenRequests)
+	assert.True(t, producer.IsTransactional())
+
+	producer.ExpectSendMessageAndSucceed()
+	_, err = p.Execute(&definitions.EngineFlowObject{}, &MockEngineFileHandler{reader: bytes.NewBufferString("payload")}, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, producer.committed)
+	assert.Equal(t, 0, producer.aborted)
+
+	producer.ExpectSendMessageAndFail(sarama.ErrOutOfBrokers)
+	_, err = p.Execute(&definitions.EngineFlowObject{}, &MockEngineFileHandler{reader: bytes.NewBufferString("payload")}, logrus.New())
+	assert.ErrorIs(t, err, sarama.ErrOutOfBrokers)
+	assert.Equal(t, 1, producer.committed)
+	assert.Equal(t, 1, producer.aborted)
+	assert.Equal(t, sarama.ProducerTxnFlagReady, producer.TxnStatus())
+
+	err = p.SetConfig(map[string]interface{}{
+		"bootstrap_servers": "localhost:9092",
+		"topic":             "billing",
+		"acks":              "local",
+		"transactional_id":  "billing-publisher",
+	})
+	assert.ErrorContains(t, err, "acks to be all")
+}
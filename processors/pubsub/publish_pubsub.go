@@ -4,8 +4,9 @@ import (
 	"cloud.google.com/go/pubsub"
 	"context"
 	"fmt"
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
-	"github.com/go-streamline/interfaces/utils"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/option"
 	"io"
@@ -13,10 +14,11 @@ import (
 
 type PublishPubSub struct {
 	definitions.BaseProcessor
-	ctx    context.Context
-	config *publishPubSubConfig
-	client *pubsub.Client
-	topic  *pubsub.Topic
+	ctx         context.Context
+	config      *publishPubSubConfig
+	client      *pubsub.Client
+	topic       *pubsub.Topic
+	exprOptions []expr.Option
 }
 
 type publishPubSubConfig struct {
@@ -26,9 +28,10 @@ type publishPubSubConfig struct {
 	CreateTopic bool   `mapstructure:"create_topic"`
 }
 
-func NewPublishPubSub() definitions.Processor {
+func NewPublishPubSub(exprOptions ...expr.Option) definitions.Processor {
 	return &PublishPubSub{
-		ctx: context.Background(),
+		ctx:         context.Background(),
+		exprOptions: exprOptions,
 	}
 }
 
@@ -44,7 +47,12 @@ func (p *PublishPubSub) SetConfig(config map[string]interface{}) error {
 		return err
 	}
 	p.config = conf
-	credentials, err := utils.EvaluateExpression(p.config.Credentials, nil)
+	credentialsExpr, err := expression.Compile(p.config.Credentials, p.exprOptions...)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to compile credentials expression")
+		return err
+	}
+	credentials, err := credentialsExpr.Evaluate(nil)
 	if err != nil {
 		logrus.WithError(err).Errorf("failed to evaluate credentials")
 		return err
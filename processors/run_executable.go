@@ -2,14 +2,18 @@ package processors
 
 import (
 	"fmt"
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
 	"github.com/sirupsen/logrus"
 	"os/exec"
 )
 
 type RunExecutable struct {
 	definitions.BaseProcessor
-	config *runExecConfig
+	config      *runExecConfig
+	args        []*expression.Expression
+	exprOptions []expr.Option
 }
 
 type runExecConfig struct {
@@ -17,8 +21,10 @@ type runExecConfig struct {
 	Args       []string `mapstructure:"args"`
 }
 
-func NewRunExecutable() definitions.Processor {
-	return &RunExecutable{}
+func NewRunExecutable(exprOptions ...expr.Option) definitions.Processor {
+	return &RunExecutable{
+		exprOptions: exprOptions,
+	}
 }
 
 func (r *RunExecutable) Close() error {
@@ -27,7 +33,12 @@ func (r *RunExecutable) Close() error {
 
 func (r *RunExecutable) SetConfig(conf map[string]interface{}) error {
 	r.config = &runExecConfig{}
-	return r.DecodeMap(conf, r.config)
+	err := r.DecodeMap(conf, r.config)
+	if err != nil {
+		return err
+	}
+	r.args, err = expression.CompileSlice(r.config.Args, r.exprOptions...)
+	return err
 }
 
 func (r *RunExecutable) Name() string {
@@ -41,9 +52,9 @@ func (r *RunExecutable) Execute(
 ) (*definitions.EngineFlowObject, error) {
 	var err error
 	// convert templated args to actual args
-	parsedArgs := make([]string, len(r.config.Args))
-	for i, arg := range r.config.Args {
-		parsedArgs[i], err = info.EvaluateExpression(arg)
+	parsedArgs := make([]string, len(r.args))
+	for i, arg := range r.args {
+		parsedArgs[i], err = arg.Evaluate(info.Metadata)
 		if err != nil {
 			return nil, fmt.Errorf("failed to evaluate expression for arg %s: %w", arg, err)
 		}
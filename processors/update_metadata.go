@@ -4,27 +4,35 @@ import (
 	"fmt"
 	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// GetStateFunction and SetStateFunction are the state helpers UpdateMetadata adds to its expressions.
+	GetStateFunction = "getState"
+	SetStateFunction = "setState"
+)
+
 type UpdateMetadata struct {
 	definitions.BaseProcessor
-	config       *map[string]any
+	metadata     map[string]*expression.Expression
 	stateManager definitions.StateManager
 	exprOptions  []expr.Option
 }
 
-func NewUpdateMetadata(stateManager definitions.StateManager) *UpdateMetadata {
+func NewUpdateMetadata(stateManager definitions.StateManager, exprOptions ...expr.Option) *UpdateMetadata {
 	return &UpdateMetadata{
 		stateManager: stateManager,
-		exprOptions: []expr.Option{
-			expr.Function("getState", func(params ...any) (any, error) {
+		// the state helpers come last so that they are not overridden by a function of the same name
+		exprOptions: append(exprOptions[:len(exprOptions):len(exprOptions)],
+			expr.Function(GetStateFunction, func(params ...any) (any, error) {
 				if len(params) != 1 {
 					return nil, fmt.Errorf("getState requires 1 parameter")
 				}
 				return stateManager.GetState(definitions.StateType(params[0].(string)))
 			}),
-			expr.Function("setState", func(params ...any) (any, error) {
+			expr.Function(SetStateFunction, func(params ...any) (any, error) {
 				if len(params) != 2 {
 					return nil, fmt.Errorf("setState requires 2 parameters")
 				}
@@ -36,7 +44,7 @@ func NewUpdateMetadata(stateManager definitions.StateManager) *UpdateMetadata {
 
 				return nil, fmt.Errorf("value must be a map")
 			}),
-		},
+		),
 	}
 }
 
@@ -51,7 +59,15 @@ func (p *UpdateMetadata) SetConfig(config map[string]interface{}) error {
 		logrus.WithError(err).Errorf("failed to decode config")
 		return err
 	}
-	p.config = conf
+
+	p.metadata = make(map[string]*expression.Expression, len(*conf))
+	for k, v := range *conf {
+		p.metadata[k], err = expression.Compile(fmt.Sprintf("%v", v), p.exprOptions...)
+		if err != nil {
+			logrus.WithError(err).Errorf("failed to compile expression for %s", k)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -63,8 +79,8 @@ func (p *UpdateMetadata) Execute(info *definitions.EngineFlowObject, fileHandler
 	log.Trace("starting UpdateMetadata execution")
 
 	var err error
-	for k, v := range *p.config {
-		info.Metadata[k], err = info.EvaluateExpression(fmt.Sprintf("%v", v), p.exprOptions...)
+	for k, v := range p.metadata {
+		info.Metadata[k], err = v.Evaluate(info.Metadata)
 		if err != nil {
 			return nil, err
 		}
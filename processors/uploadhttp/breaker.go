@@ -0,0 +1,171 @@
+package uploadhttp
+
+import (
+	"fmt"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const defaultCircuitBreakerCooldown = 30 * time.Second
+
+type circuitBreakerConfig struct {
+	FailureThreshold int    `mapstructure:"failure_threshold,omitempty"` // consecutive failures that open the circuit, disabled if not set
+	Cooldown         string `mapstructure:"cooldown,omitempty"`          // time the circuit stays open before a trial request
+}
+
+type rateLimitConfig struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second,omitempty"` // per host, unlimited if not set
+	Burst             int     `mapstructure:"burst,omitempty"`
+}
+
+// CircuitOpenError is returned without sending the request while the circuit breaker of its host is open.
+type CircuitOpenError struct {
+	Host    string
+	RetryIn time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker of %s is open, next trial in %s", e.Host, e.RetryIn.Round(time.Millisecond))
+}
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// hostGuards rate limits the requests to every host and stops sending to hosts that keep failing.
+// A circuit opens after failureThreshold consecutive failures, and after the cooldown lets a single
+// trial request through, whose outcome closes or reopens it.
+type hostGuards struct {
+	mu               sync.Mutex
+	failureThreshold int
+	cooldown         time.Duration
+	limit            rate.Limit
+	burst            int
+	hosts            map[string]*hostGuard
+	now              func() time.Time
+}
+
+type hostGuard struct {
+	limiter  *rate.Limiter
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+func newHostGuards(breaker circuitBreakerConfig, rateLimit rateLimitConfig) (*hostGuards, error) {
+	if breaker.FailureThreshold < 0 {
+		return nil, fmt.Errorf("circuit_breaker failure_threshold must not be negative")
+	}
+	if rateLimit.RequestsPerSecond < 0 || rateLimit.Burst < 0 {
+		return nil, fmt.Errorf("rate_limit requests_per_second and burst must not be negative")
+	}
+	cooldown, err := httpclient.ParseDuration(breaker.Cooldown, defaultCircuitBreakerCooldown)
+	if err != nil {
+		return nil, fmt.Errorf("circuit_breaker cooldown: %w", err)
+	}
+	g := &hostGuards{
+		failureThreshold: breaker.FailureThreshold,
+		cooldown:         cooldown,
+		limit:            rate.Inf,
+		hosts:            make(map[string]*hostGuard),
+		now:              time.Now,
+	}
+	if rateLimit.RequestsPerSecond > 0 {
+		g.limit = rate.Limit(rateLimit.RequestsPerSecond)
+		g.burst = rateLimit.Burst
+		if g.burst == 0 {
+			g.burst = int(math.Max(1, math.Ceil(rateLimit.RequestsPerSecond)))
+		}
+	}
+	return g, nil
+}
+
+func (g *hostGuards) host(host string) *hostGuard {
+	guard, ok := g.hosts[host]
+	if !ok {
+		guard = &hostGuard{limiter: rate.NewLimiter(g.limit, g.burst)}
+		g.hosts[host] = guard
+	}
+	return guard
+}
+
+// acquire waits for the rate limit of the host of req and checks its circuit.
+func (g *hostGuards) acquire(log *logrus.Logger, req *http.Request) error {
+	host := req.URL.Host
+	g.mu.Lock()
+	limiter := g.host(host).limiter
+	g.mu.Unlock()
+	err := limiter.Wait(req.Context())
+	if err != nil {
+		return fmt.Errorf("rate limit of %s: %w", host, err)
+	}
+
+	if g.failureThreshold == 0 {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	guard := g.host(host)
+	switch guard.state {
+	case circuitOpen:
+		elapsed := g.now().Sub(guard.openedAt)
+		if elapsed < g.cooldown {
+			return &CircuitOpenError{Host: host, RetryIn: g.cooldown - elapsed}
+		}
+		log.Infof("circuit breaker of %s is half-open, sending a trial request", host)
+		guard.state = circuitHalfOpen
+	case circuitHalfOpen:
+		// the trial request is still in flight
+		return &CircuitOpenError{Host: host, RetryIn: g.cooldown}
+	}
+	return nil
+}
+
+// record updates the circuit of the host of req with the outcome of sending it.
+func (g *hostGuards) record(log *logrus.Logger, req *http.Request, success bool) {
+	if g.failureThreshold == 0 {
+		return
+	}
+	host := req.URL.Host
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	guard := g.host(host)
+	if success {
+		if guard.state != circuitClosed {
+			log.Infof("circuit breaker of %s is closed", host)
+		}
+		guard.state = circuitClosed
+		guard.failures = 0
+		return
+	}
+	guard.failures++
+	if guard.state == circuitOpen {
+		return
+	}
+	if guard.state == circuitHalfOpen || guard.failures >= g.failureThreshold {
+		log.Warnf("circuit breaker of %s is open after %d consecutive failures", host, guard.failures)
+		guard.state = circuitOpen
+		guard.openedAt = g.now()
+	}
+}
+
+// do sends req through the rate limit and circuit breaker of its host. Transport errors and 5xx
+// responses count as failures.
+func (h *UploadHTTP) do(log *logrus.Logger, req *http.Request) (*http.Response, error) {
+	err := h.guards.acquire(log, req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	h.guards.record(log, req, err == nil && resp.StatusCode < 500)
+	return resp, err
+}
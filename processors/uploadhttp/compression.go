@@ -0,0 +1,136 @@
+package uploadhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type compressionType string
+
+const (
+	compressionNone compressionType = ""
+	compressionGzip compressionType = "gzip"
+	compressionZstd compressionType = "zstd"
+)
+
+// acceptedEncodings is sent with every request that does not set Accept-Encoding itself, the
+// responses are then decompressed in handleResponse.
+const acceptedEncodings = "gzip, zstd"
+
+func validateCompression(compression compressionType, level int) error {
+	switch compression {
+	case compressionNone:
+	case compressionGzip:
+		if level != 0 && (level < gzip.HuffmanOnly || level > gzip.BestCompression) {
+			return fmt.Errorf("invalid gzip compression level %d", level)
+		}
+	case compressionZstd:
+		if level < 0 || level > 22 {
+			return fmt.Errorf("invalid zstd compression level %d", level)
+		}
+	default:
+		return fmt.Errorf("unsupported compression %s", compression)
+	}
+	return nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// newCompressor wraps w so everything written to it is compressed. Closing the compressor flushes
+// it without closing w. A level of 0 selects the default level of the algorithm.
+func newCompressor(compression compressionType, level int, w io.Writer) (io.WriteCloser, error) {
+	switch compression {
+	case compressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case compressionZstd:
+		options := []zstd.EOption{}
+		if level != 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, options...)
+	default:
+		return nopWriteCloser{w}, nil
+	}
+}
+
+func compressBuffer(compression compressionType, level int, data []byte) (*bytes.Buffer, error) {
+	var compressed bytes.Buffer
+	compressor, err := newCompressor(compression, level, &compressed)
+	if err != nil {
+		return nil, err
+	}
+	_, err = compressor.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	err = compressor.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &compressed, nil
+}
+
+type zstdReadCloser struct {
+	decoder *zstd.Decoder
+	body    io.Closer
+}
+
+func (z *zstdReadCloser) Read(p []byte) (int, error) {
+	return z.decoder.Read(p)
+}
+
+func (z *zstdReadCloser) Close() error {
+	z.decoder.Close()
+	return z.body.Close()
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (g *gzipReadCloser) Close() error {
+	_ = g.Reader.Close()
+	return g.body.Close()
+}
+
+// decompressResponse replaces the body of resp with its decompressed form, if it was compressed
+// with one of the accepted encodings.
+func decompressResponse(resp *http.Response) error {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	switch encoding {
+	case "gzip":
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress gzip response: %w", err)
+		}
+		resp.Body = &gzipReadCloser{Reader: reader, body: resp.Body}
+	case "zstd":
+		decoder, err := zstd.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decompress zstd response: %w", err)
+		}
+		resp.Body = &zstdReadCloser{decoder: decoder, body: resp.Body}
+	default:
+		return nil
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
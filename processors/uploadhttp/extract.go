@@ -0,0 +1,92 @@
+package uploadhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/jsonpath"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// responseExtractor sets a metadata key from the parsed response, using either a JSONPath or an expr.
+type responseExtractor struct {
+	key     string
+	path    *jsonpath.Path
+	program *vm.Program
+}
+
+func compileResponseExtract(extract map[string]string, exprOptions ...expr.Option) ([]responseExtractor, error) {
+	var extractors []responseExtractor
+	for key, source := range extract {
+		extractor := responseExtractor{key: key}
+		var err error
+		if jsonpath.IsPath(source) {
+			extractor.path, err = jsonpath.Compile(source)
+		} else {
+			extractor.program, err = expr.Compile(source, exprOptions...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("response_extract %s: %w", key, err)
+		}
+		extractors = append(extractors, extractor)
+	}
+	return extractors, nil
+}
+
+// extractResponse copies the configured response headers and extracted body fields to the metadata.
+// The expr extractors see the parsed body as `body`, the headers as `headers` and the status code as `status`.
+func (h *UploadHTTP) extractResponse(
+	log *logrus.Logger,
+	info *definitions.EngineFlowObject,
+	resp *http.Response,
+	body []byte,
+) error {
+	for key, header := range h.config.ResponseHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			info.Metadata[key] = value
+		}
+	}
+
+	if len(h.compiled.extract) == 0 {
+		return nil
+	}
+
+	var parsed any
+	err := json.Unmarshal(body, &parsed)
+	if err != nil {
+		log.WithError(err).Errorf("failed to parse response body as JSON")
+		return fmt.Errorf("failed to parse response body as JSON: %w", err)
+	}
+
+	headers := make(map[string]string, len(resp.Header))
+	for key := range resp.Header {
+		headers[key] = resp.Header.Get(key)
+	}
+	env := map[string]any{
+		"body":    parsed,
+		"headers": headers,
+		"status":  resp.StatusCode,
+	}
+
+	for _, extractor := range h.compiled.extract {
+		if extractor.path != nil {
+			value, ok := extractor.path.Get(parsed)
+			if !ok {
+				log.Debugf("%s not found in response, %s is left unset", extractor.path, extractor.key)
+				continue
+			}
+			info.Metadata[extractor.key] = value
+			continue
+		}
+		value, err := expr.Run(extractor.program, env)
+		if err != nil {
+			log.WithError(err).Errorf("failed to extract %s from response", extractor.key)
+			return fmt.Errorf("failed to extract %s from response: %w", extractor.key, err)
+		}
+		info.Metadata[extractor.key] = value
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package uploadhttp
+
+import (
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/statevalue"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	idempotencyStatePrefix   = "idempotency:"
+	defaultIdempotencyHeader = "Idempotency-Key"
+	defaultIdempotencyTTL    = 24 * time.Hour
+	idempotencySweepInterval = time.Minute
+	// every record is written with the whole local state, so only small bodies are kept by default
+	defaultIdempotencyMaxBody = 64 << 10
+)
+
+// idempotencyRecord is the response to a request whose idempotency key already succeeded.
+type idempotencyRecord struct {
+	status    int
+	body      string
+	headers   http.Header
+	truncated bool // the body exceeded idempotency_max_body and was not stored
+}
+
+// response rebuilds the stored response, so it is handled like the original one.
+func (r *idempotencyRecord) response() *http.Response {
+	return &http.Response{
+		StatusCode:    r.status,
+		Status:        fmt.Sprintf("%d %s", r.status, http.StatusText(r.status)),
+		Header:        r.headers,
+		Body:          io.NopCloser(strings.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+	}
+}
+
+// loadIdempotencyRecord returns the record of key, or nil if the key has not succeeded yet or its
+// record expired.
+func (h *UploadHTTP) loadIdempotencyRecord(key string) (*idempotencyRecord, error) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	state, err := h.localState()
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := state[idempotencyStatePrefix+key].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	if expiresAt, ok := statevalue.Int64(entry["expires_at"]); !ok || time.Now().Unix() >= expiresAt {
+		return nil, nil
+	}
+	status, ok := statevalue.Int64(entry["status"])
+	if !ok {
+		return nil, fmt.Errorf("invalid status in the record of idempotency key %s", key)
+	}
+	record := &idempotencyRecord{status: int(status), headers: make(http.Header)}
+	record.body, _ = entry["body"].(string)
+	record.truncated, _ = entry["truncated"].(bool)
+	if headers, ok := entry["headers"].(map[string]any); ok {
+		for name, values := range headers {
+			switch values := values.(type) {
+			case []string:
+				record.headers[name] = values
+			case []any:
+				for _, value := range values {
+					record.headers.Add(name, fmt.Sprintf("%v", value))
+				}
+			}
+		}
+	}
+	return record, nil
+}
+
+// storeIdempotencyRecord remembers the response of key until the TTL passes. Each record has a
+// state key of its own, and the records are only scanned for expired ones once per
+// idempotencySweepInterval rather than on every store. A body larger than idempotency_max_body is
+// not stored, and the record only keeps the status and headers.
+func (h *UploadHTTP) storeIdempotencyRecord(key string, resp *http.Response, body []byte, truncated bool) error {
+	if truncated || len(body) > h.config.IdempotencyMaxBody {
+		body, truncated = nil, true
+	}
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	state, err := h.localState()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if now.Sub(h.idempotencySwept) >= idempotencySweepInterval {
+		for stateKey, value := range state {
+			if !strings.HasPrefix(stateKey, idempotencyStatePrefix) {
+				continue
+			}
+			entry, ok := value.(map[string]any)
+			if !ok {
+				continue
+			}
+			if expiresAt, ok := statevalue.Int64(entry["expires_at"]); ok && now.Unix() >= expiresAt {
+				delete(state, stateKey)
+			}
+		}
+		h.idempotencySwept = now
+	}
+
+	headers := make(map[string]any, len(resp.Header))
+	for name, values := range resp.Header {
+		headers[name] = values
+	}
+	state[idempotencyStatePrefix+key] = map[string]any{
+		"status":     resp.StatusCode,
+		"body":       string(body),
+		"truncated":  truncated,
+		"headers":    headers,
+		"expires_at": now.Add(h.idempotencyTTL).Unix(),
+	}
+	return h.stateManager.SetState(definitions.StateTypeLocal, state)
+}
+
+// localState returns the local state of the processor. It is read from the state manager once and
+// then kept in memory, as the processor is the only one writing it, so looking up a key does not
+// read the whole state. h.stateMu must be held.
+func (h *UploadHTTP) localState() (map[string]any, error) {
+	if h.state != nil {
+		return h.state, nil
+	}
+	state, err := h.stateManager.GetState(definitions.StateTypeLocal)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		state = make(map[string]any)
+	}
+	h.state = state
+	return state, nil
+}
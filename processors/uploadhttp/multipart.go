@@ -0,0 +1,118 @@
+package uploadhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"github.com/sirupsen/logrus"
+	"mime/multipart"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// multipartField is an extra part of the multipart form. It can be configured either as a plain
+// value or as a map with a value and a content type.
+type multipartField struct {
+	Value       string `mapstructure:"value"`
+	ContentType string `mapstructure:"content_type,omitempty"`
+}
+
+type compiledMultipartField struct {
+	name        string
+	value       *expression.Expression
+	contentType string
+}
+
+func (h *UploadHTTP) compileMultipartFields(fields map[string]interface{}) ([]compiledMultipartField, error) {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	// parts are written in a stable order so the form does not change between flow files
+	sort.Strings(names)
+
+	compiled := make([]compiledMultipartField, 0, len(fields))
+	for _, name := range names {
+		field := multipartField{}
+		switch value := fields[name].(type) {
+		case string:
+			field.Value = value
+		case map[string]interface{}:
+			err := h.DecodeMap(value, &field)
+			if err != nil {
+				return nil, fmt.Errorf("multipart_fields %s: %w", name, err)
+			}
+		default:
+			field.Value = fmt.Sprintf("%v", value)
+		}
+
+		e, err := expression.Compile(field.Value, h.exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("multipart_fields %s: %w", name, err)
+		}
+		compiled = append(compiled, compiledMultipartField{name: name, value: e, contentType: field.ContentType})
+	}
+	return compiled, nil
+}
+
+// writeMultipartFields writes the extra fields and the metadata part, which precede the file part.
+func (h *UploadHTTP) writeMultipartFields(
+	log *logrus.Logger,
+	info *definitions.EngineFlowObject,
+	writer *multipart.Writer,
+) error {
+	for _, field := range h.compiled.multipartFields {
+		value, err := evaluateAndLog(log, info, field.value, "multipart field "+field.name)
+		if err != nil {
+			return err
+		}
+		err = writeFormPart(writer, field.name, field.contentType, []byte(value))
+		if err != nil {
+			log.WithError(err).Errorf("failed to write multipart field %s", field.name)
+			return fmt.Errorf("failed to write multipart field %s: %w", field.name, err)
+		}
+	}
+
+	if h.config.MultipartMetadataPart == "" {
+		return nil
+	}
+	metadata := info.Metadata
+	if len(h.config.MultipartMetadataKeys) > 0 {
+		metadata = make(map[string]interface{}, len(h.config.MultipartMetadataKeys))
+		for _, key := range h.config.MultipartMetadataKeys {
+			if value, ok := info.Metadata[key]; ok {
+				metadata[key] = value
+			}
+		}
+	}
+	metadataJSON, err := json.Marshal(metadata)
+	if err != nil {
+		log.WithError(err).Errorf("failed to marshal metadata part")
+		return fmt.Errorf("failed to marshal metadata part: %w", err)
+	}
+	log.Debugf("writing metadata part %s", h.config.MultipartMetadataPart)
+	err = writeFormPart(writer, h.config.MultipartMetadataPart, "application/json", metadataJSON)
+	if err != nil {
+		log.WithError(err).Errorf("failed to write metadata part")
+		return fmt.Errorf("failed to write metadata part: %w", err)
+	}
+	return nil
+}
+
+func writeFormPart(writer *multipart.Writer, name, contentType string, value []byte) error {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name)))
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(value)
+	return err
+}
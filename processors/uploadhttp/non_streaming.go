@@ -13,7 +13,7 @@ import (
 
 func (h *UploadHTTP) generateMemoryLoaderRequest(
 	log *logrus.Logger,
-	url string,
+	method, url string,
 	info *definitions.EngineFlowObject,
 	reader io.Reader,
 ) (*http.Request, error) {
@@ -38,7 +38,6 @@ func (h *UploadHTTP) generateMemoryLoaderRequest(
 		log.Debugf("Sending file as base64")
 		var base64Content bytes.Buffer
 		base64Writer := base64.NewEncoder(base64.StdEncoding, &base64Content)
-		defer base64Writer.Close()
 		log.Debugf("copying file to base64")
 		_, err := io.Copy(base64Writer, reader)
 		if err != nil {
@@ -46,6 +45,11 @@ func (h *UploadHTTP) generateMemoryLoaderRequest(
 			return nil, fmt.Errorf("failed to copy file to base64: %w", err)
 		}
 		log.Debugf("closing base64 writer")
+		err = base64Writer.Close()
+		if err != nil {
+			log.WithError(err).Errorf("failed to close base64 writer")
+			return nil, fmt.Errorf("failed to close base64 writer: %w", err)
+		}
 		formattedContent, err := h.formatBase64Content(base64Content.String(), info)
 		if err != nil {
 			log.WithError(err).Errorf("failed to format base64 content")
@@ -56,10 +60,39 @@ func (h *UploadHTTP) generateMemoryLoaderRequest(
 			log.WithError(err).Errorf("failed to write formatted content")
 			return nil, fmt.Errorf("failed to write formatted content: %w", err)
 		}
+	case sendFileRaw:
+		log.Debugf("sending file as raw body with memory loader")
+		var err error
+		contentType, err = evaluateAndLog(log, info, h.compiled.contentType, "content type")
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(&requestBody, reader)
+		if err != nil {
+			log.WithError(err).Errorf("failed to copy file to request body")
+			return nil, fmt.Errorf("failed to copy file to request body: %w", err)
+		}
+	case sendFileNone:
+		log.Debugf("sending request without body")
+	}
+
+	// Debugging: Log the request body content for inspection
+	log.Debugf("Request Body: %s", truncateForLog(requestBody.Bytes()))
+
+	body := &requestBody
+	compressed := h.config.Compression != compressionNone && h.config.Type != sendFileNone
+	if compressed {
+		var err error
+		body, err = compressBuffer(h.config.Compression, h.config.CompressionLevel, requestBody.Bytes())
+		if err != nil {
+			log.WithError(err).Errorf("failed to compress request body")
+			return nil, fmt.Errorf("failed to compress request body: %w", err)
+		}
+		log.Debugf("compressed request body with %s from %d to %d bytes", h.config.Compression, requestBody.Len(), body.Len())
 	}
 
 	// Ensure the writer is closed to finalize the multipart content
-	req, err := http.NewRequest("POST", url, &requestBody)
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		log.WithError(err).Errorf("failed to create HTTP request")
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
@@ -67,8 +100,9 @@ func (h *UploadHTTP) generateMemoryLoaderRequest(
 	if contentType != "" {
 		req.Header.Set("Content-Type", contentType)
 	}
-	// Debugging: Log the request body content for inspection
-	log.Debugf("Request Body: %s", requestBody.String())
+	if compressed {
+		req.Header.Set("Content-Encoding", string(h.config.Compression))
+	}
 
 	return req, nil
 }
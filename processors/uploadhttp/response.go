@@ -0,0 +1,154 @@
+package uploadhttp
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+)
+
+const (
+	defaultMaxResponseMetadataBody = 64 * 1024
+	// defaultMaxResponseExtractBody bounds the body parsed by response_extract when max_response_body is not set
+	defaultMaxResponseExtractBody = 1024 * 1024
+)
+
+// boundedBuffer keeps the first limit bytes written to it and silently drops the rest.
+type boundedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	remaining := b.limit - b.buf.Len()
+	if remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+// prefix returns the first n bytes of the buffer.
+func (b *boundedBuffer) prefix(n int) string {
+	if b.buf.Len() <= n {
+		return b.buf.String()
+	}
+	return string(b.buf.Bytes()[:n]) + "...(truncated)"
+}
+
+func truncateForLog(body []byte) string {
+	if len(body) <= httpclient.MaxLoggedBodySize {
+		return string(body)
+	}
+	return string(body[:httpclient.MaxLoggedBodySize]) + "...(truncated)"
+}
+
+// handleResponse checks the status of resp and streams its body to where it was configured to go,
+// without ever holding more of it in memory than the metadata, the idempotency record and logs
+// need. It returns the body that was kept, and whether it is only the start of the body.
+func (h *UploadHTTP) handleResponse(
+	log *logrus.Logger,
+	info *definitions.EngineFlowObject,
+	fileHandler definitions.ProcessorFileHandler,
+	resp *http.Response,
+) ([]byte, bool, error) {
+	err := decompressResponse(resp)
+	if err != nil {
+		log.WithError(err).Errorf("failed to decompress response")
+		return nil, false, fmt.Errorf("failed to decompress response: %w", err)
+	}
+
+	keepBody := h.config.WriteResponseToMetadata || h.compiled.idempotencyKey != nil
+	captureLimit := httpclient.MaxLoggedBodySize
+	if keepBody && h.config.MaxResponseMetadataBody > captureLimit {
+		captureLimit = h.config.MaxResponseMetadataBody
+	}
+	if h.compiled.idempotencyKey != nil {
+		// a byte past idempotency_max_body tells whether the body fits the record
+		captureLimit = max(captureLimit, h.config.IdempotencyMaxBody+1)
+	}
+	captured := &boundedBuffer{limit: captureLimit}
+
+	body := io.TeeReader(httpclient.LimitBody(resp.Body, h.config.MaxResponseBody), captured)
+	var extractBody *boundedBuffer
+	if len(h.compiled.extract) > 0 {
+		extractBody = &boundedBuffer{limit: defaultMaxResponseExtractBody}
+		if h.config.MaxResponseBody > 0 {
+			extractBody.limit = int(h.config.MaxResponseBody)
+		}
+		body = io.TeeReader(body, extractBody)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_, _ = io.CopyN(io.Discard, body, httpclient.MaxLoggedBodySize)
+		log.Errorf("received non-2xx response: %s, body: %s", resp.Status, captured.prefix(httpclient.MaxLoggedBodySize))
+		return nil, false, fmt.Errorf("received non-2xx response: %s", resp.Status)
+	}
+	log.Debugf("Response status: %s", resp.Status)
+
+	var read int64
+	if h.config.PutResponseAsContents {
+		writer, err := fileHandler.Write()
+		if err != nil {
+			log.WithError(err).Errorf("failed to write response to file")
+			return nil, false, fmt.Errorf("failed to write response to file: %w", err)
+		}
+		read, err = io.Copy(writer, body)
+		if err != nil {
+			log.WithError(err).Errorf("failed to write response to file")
+			return nil, false, fmt.Errorf("failed to write response to file: %w", err)
+		}
+	} else if len(h.compiled.extract) > 0 {
+		read, err = io.Copy(io.Discard, body)
+		if err != nil {
+			log.WithError(err).Errorf("failed to read response body")
+			return nil, false, fmt.Errorf("failed to read response body: %w", err)
+		}
+	} else if keepBody {
+		read, err = io.CopyN(io.Discard, body, int64(captureLimit)+1)
+		if err != nil && err != io.EOF {
+			log.WithError(err).Errorf("failed to read response body")
+			return nil, false, fmt.Errorf("failed to read response body: %w", err)
+		}
+	}
+
+	err = httpclient.CheckBodySize(read, h.config.MaxResponseBody)
+	if err != nil {
+		log.WithError(err).Errorf("response body is too large")
+		return nil, false, err
+	}
+	log.Debugf("Response body: %s", captured.prefix(httpclient.MaxLoggedBodySize))
+
+	metadataBody := captured.buf.Bytes()
+	truncated := captured.truncated
+	if len(metadataBody) > h.config.MaxResponseMetadataBody {
+		metadataBody = metadataBody[:h.config.MaxResponseMetadataBody]
+		truncated = true
+	}
+	if h.config.WriteResponseToMetadata {
+		info.Metadata["UploadHTTP.ResponseBody"] = string(metadataBody)
+		info.Metadata["UploadHTTP.ResponseBodyTruncated"] = truncated
+		info.Metadata["UploadHTTP.ResponseHeaders"] = resp.Header
+	}
+	var extracted []byte
+	if extractBody != nil {
+		if extractBody.truncated {
+			log.Errorf("response body exceeds the %d bytes parsed by response_extract", extractBody.limit)
+			return nil, false, fmt.Errorf("response body exceeds the %d bytes parsed by response_extract", extractBody.limit)
+		}
+		extracted = extractBody.buf.Bytes()
+	}
+	err = h.extractResponse(log, info, resp, extracted)
+	if err != nil {
+		return nil, false, err
+	}
+	return captured.buf.Bytes(), captured.truncated, nil
+}
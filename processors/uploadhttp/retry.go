@@ -0,0 +1,363 @@
+package uploadhttp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/sirupsen/logrus"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type transportErrorKind string
+
+const (
+	transportErrorTimeout           transportErrorKind = "timeout"
+	transportErrorConnectionRefused transportErrorKind = "connection_refused"
+	transportErrorConnectionReset   transportErrorKind = "connection_reset"
+	transportErrorEOF               transportErrorKind = "eof"
+	transportErrorDNS               transportErrorKind = "dns"
+	transportErrorAny               transportErrorKind = "any"
+)
+
+var (
+	defaultRetryStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+	defaultRetryTransportErrors = []transportErrorKind{
+		transportErrorTimeout,
+		transportErrorConnectionRefused,
+		transportErrorConnectionReset,
+		transportErrorEOF,
+	}
+)
+
+type retryConfig struct {
+	MaxAttempts      int      `mapstructure:"max_attempts,omitempty"`
+	InitialBackoff   string   `mapstructure:"initial_backoff,omitempty"`
+	MaxBackoff       string   `mapstructure:"max_backoff,omitempty"`
+	Multiplier       float64  `mapstructure:"multiplier,omitempty"`
+	Jitter           float64  `mapstructure:"jitter,omitempty"` // fraction of the backoff to randomize, between 0 and 1
+	StatusCodes      []int    `mapstructure:"status_codes,omitempty"`
+	TransportErrors  []string `mapstructure:"transport_errors,omitempty"`
+	IgnoreRetryAfter bool     `mapstructure:"ignore_retry_after,omitempty"`
+}
+
+type retryPolicy struct {
+	maxAttempts      int
+	initialBackoff   time.Duration
+	maxBackoff       time.Duration
+	multiplier       float64
+	jitter           float64
+	statusCodes      map[int]bool
+	transportErrors  map[transportErrorKind]bool
+	ignoreRetryAfter bool
+	sleep            func(time.Duration)
+}
+
+func newRetryPolicy(conf retryConfig) (*retryPolicy, error) {
+	var err error
+	p := &retryPolicy{
+		maxAttempts:      conf.MaxAttempts,
+		multiplier:       conf.Multiplier,
+		jitter:           conf.Jitter,
+		statusCodes:      make(map[int]bool),
+		transportErrors:  make(map[transportErrorKind]bool),
+		ignoreRetryAfter: conf.IgnoreRetryAfter,
+		sleep:            time.Sleep,
+	}
+	if p.maxAttempts <= 0 {
+		p.maxAttempts = 1
+	}
+	if p.multiplier <= 0 {
+		p.multiplier = 2
+	}
+	if p.jitter < 0 || p.jitter > 1 {
+		return nil, fmt.Errorf("jitter must be between 0 and 1")
+	}
+
+	p.initialBackoff, err = httpclient.ParseDuration(conf.InitialBackoff, 500*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid initial_backoff: %w", err)
+	}
+	p.maxBackoff, err = httpclient.ParseDuration(conf.MaxBackoff, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("invalid max_backoff: %w", err)
+	}
+
+	statusCodes := conf.StatusCodes
+	if len(statusCodes) == 0 {
+		statusCodes = defaultRetryStatusCodes
+	}
+	for _, code := range statusCodes {
+		p.statusCodes[code] = true
+	}
+
+	if len(conf.TransportErrors) == 0 {
+		for _, kind := range defaultRetryTransportErrors {
+			p.transportErrors[kind] = true
+		}
+	}
+	for _, kind := range conf.TransportErrors {
+		switch transportErrorKind(kind) {
+		case transportErrorTimeout, transportErrorConnectionRefused, transportErrorConnectionReset,
+			transportErrorEOF, transportErrorDNS, transportErrorAny:
+			p.transportErrors[transportErrorKind(kind)] = true
+		default:
+			return nil, fmt.Errorf("unsupported transport error %s", kind)
+		}
+	}
+	return p, nil
+}
+
+func (p *retryPolicy) shouldRetryStatus(statusCode int) bool {
+	return p.statusCodes[statusCode]
+}
+
+func (p *retryPolicy) shouldRetryError(err error) bool {
+	var circuitErr *CircuitOpenError
+	if errors.As(err, &circuitErr) {
+		// failing fast is the point of an open circuit
+		return false
+	}
+	if p.transportErrors[transportErrorAny] {
+		return true
+	}
+	var netErr net.Error
+	if p.transportErrors[transportErrorTimeout] && errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	if p.transportErrors[transportErrorConnectionRefused] && errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if p.transportErrors[transportErrorConnectionReset] && (errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)) {
+		return true
+	}
+	if p.transportErrors[transportErrorEOF] && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return p.transportErrors[transportErrorDNS] && errors.As(err, &dnsErr)
+}
+
+// backoff returns how long to wait before the attempt following the given one. A Retry-After
+// header on resp takes precedence over the exponential backoff unless it is ignored, and is capped
+// by the maximum backoff so a server cannot hold the flow file for longer than configured.
+func (p *retryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil && !p.ignoreRetryAfter {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(delay, p.maxBackoff)
+		}
+	}
+
+	delay := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(attempt-1))
+	if p.jitter > 0 {
+		delay += delay * p.jitter * (2*rand.Float64() - 1)
+	}
+	// clamped after the jitter, so the jitter cannot push the delay past the maximum backoff
+	return time.Duration(min(max(delay, 0), float64(p.maxBackoff)))
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// contentSource hands out the flow file contents to every attempt, rewinding them when an attempt is replayed.
+type contentSource struct {
+	fileHandler definitions.ProcessorFileHandler
+	reader      io.Reader
+}
+
+func (s *contentSource) next() (io.Reader, error) {
+	if s.reader != nil {
+		if seeker, ok := s.reader.(io.Seeker); ok {
+			_, err := seeker.Seek(0, io.SeekStart)
+			return s.reader, err
+		}
+	}
+	reader, err := s.fileHandler.Read()
+	if err != nil {
+		return nil, err
+	}
+	s.reader = reader
+	return reader, nil
+}
+
+// guardedBody is the body of a streamed request. It makes sure that nothing reads the flow file
+// contents anymore once an attempt is released, so the next attempt can safely rewind them.
+type guardedBody struct {
+	mu      sync.Mutex
+	reader  io.Reader
+	closer  io.Closer
+	closed  bool
+	writers sync.WaitGroup
+}
+
+// newGuardedBody guards reader. The closer, if any, is closed along with the body to stop its writers.
+func newGuardedBody(reader io.Reader, closer io.Closer) *guardedBody {
+	return &guardedBody{reader: reader, closer: closer}
+}
+
+func (b *guardedBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return 0, io.ErrClosedPipe
+	}
+	return b.reader.Read(p)
+}
+
+func (b *guardedBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	if b.closer != nil {
+		return b.closer.Close()
+	}
+	return nil
+}
+
+// wrap replaces the reader of the body with the one returned by wrapper, before the body is read.
+func (b *guardedBody) wrap(wrapper func(io.Reader) io.Reader) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.reader = wrapper(b.reader)
+}
+
+// release closes the body and waits for the goroutines writing it to return.
+func (b *guardedBody) release() {
+	_ = b.Close()
+	b.writers.Wait()
+}
+
+func (h *UploadHTTP) sendWithRetries(
+	log *logrus.Logger,
+	info *definitions.EngineFlowObject,
+	source *contentSource,
+	method, url string,
+	headers http.Header,
+) (*http.Response, int, error) {
+	var req *http.Request
+	for attempt := 1; ; attempt++ {
+		var err error
+		req, err = h.generateRequest(log, info, source, method, url, headers, req)
+		if err != nil {
+			return nil, attempt, err
+		}
+		err = h.auth.Apply(req, info)
+		if err != nil {
+			log.WithError(err).Errorf("failed to authenticate request")
+			return nil, attempt, fmt.Errorf("failed to authenticate request: %w", err)
+		}
+		err = h.signer.sign(req, info)
+		if err != nil {
+			log.WithError(err).Errorf("failed to sign request")
+			return nil, attempt, fmt.Errorf("failed to sign request: %w", err)
+		}
+		log.Debugf("sending %s %s with headers %v", req.Method, h.auth.RedactURL(req.URL), h.auth.RedactHeaders(req.Header))
+
+		resp, err := h.do(log, req)
+		if err != nil {
+			var urlErr *neturl.Error
+			if errors.As(err, &urlErr) {
+				urlErr.URL = h.auth.RedactURL(req.URL)
+			}
+			if attempt >= h.retry.maxAttempts || !h.retry.shouldRetryError(err) {
+				log.WithError(err).Errorf("failed to send HTTP request")
+				return nil, attempt, fmt.Errorf("failed to send HTTP request: %w", err)
+			}
+			delay := h.retry.backoff(attempt, nil)
+			log.WithError(err).Warnf("attempt %d of %d failed, retrying in %s", attempt, h.retry.maxAttempts, delay)
+			h.retry.sleep(delay)
+			continue
+		}
+
+		if attempt < h.retry.maxAttempts && h.retry.shouldRetryStatus(resp.StatusCode) {
+			delay := h.retry.backoff(attempt, resp)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			log.Warnf("attempt %d of %d received %s, retrying in %s", attempt, h.retry.maxAttempts, resp.Status, delay)
+			h.retry.sleep(delay)
+			continue
+		}
+		return resp, attempt, nil
+	}
+}
+
+// generateRequest creates the request of an attempt. Buffered bodies of a previous attempt are
+// replayed as they are, while streamed ones are generated again from the rewound contents.
+func (h *UploadHTTP) generateRequest(
+	log *logrus.Logger,
+	info *definitions.EngineFlowObject,
+	source *contentSource,
+	method, url string,
+	headers http.Header,
+	previous *http.Request,
+) (*http.Request, error) {
+	if previous != nil {
+		if body, ok := previous.Body.(*guardedBody); ok {
+			body.release()
+		} else {
+			log.Debugf("replaying request body")
+			req := previous.Clone(previous.Context())
+			if previous.GetBody != nil {
+				var err error
+				req.Body, err = previous.GetBody()
+				if err != nil {
+					log.WithError(err).Errorf("failed to replay request body")
+					return nil, fmt.Errorf("failed to replay request body: %w", err)
+				}
+			}
+			return req, nil
+		}
+	}
+
+	reader, err := source.next()
+	if err != nil {
+		log.WithError(err).Errorf("failed to read file")
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	var req *http.Request
+	if h.config.UseStreaming {
+		req, err = h.generateStreamingRequest(log, method, url, info, reader)
+	} else {
+		req, err = h.generateMemoryLoaderRequest(log, method, url, info, reader)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	return req, nil
+}
@@ -0,0 +1,235 @@
+package uploadhttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type signingType string
+
+const (
+	signingNone     signingType = ""
+	signingHMAC     signingType = "hmac"
+	signingAWSSigV4 signingType = "aws_sigv4"
+)
+
+type payloadSigning string
+
+const (
+	payloadUnsigned payloadSigning = "unsigned"
+	payloadChunked  payloadSigning = "chunked"
+)
+
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Timestamp"
+	defaultAWSChunkSize    = 64 * 1024
+	minAWSChunkSize        = 8 * 1024
+)
+
+type signingConfig struct {
+	Type              signingType    `mapstructure:"type"`
+	Secret            string         `mapstructure:"secret,omitempty"`
+	SecretFile        string         `mapstructure:"secret_file,omitempty"`
+	SignatureHeader   string         `mapstructure:"signature_header,omitempty"`
+	TimestampHeader   string         `mapstructure:"timestamp_header,omitempty"`
+	SignaturePrefix   string         `mapstructure:"signature_prefix,omitempty"`   // e.g. sha256=
+	SignatureEncoding string         `mapstructure:"signature_encoding,omitempty"` // hex or base64
+	AccessKeyID       string         `mapstructure:"access_key_id,omitempty"`
+	SecretAccessKey   string         `mapstructure:"secret_access_key,omitempty"`
+	SessionToken      string         `mapstructure:"session_token,omitempty"`
+	Region            string         `mapstructure:"region,omitempty"`
+	Service           string         `mapstructure:"service,omitempty"`
+	PayloadSigning    payloadSigning `mapstructure:"payload_signing,omitempty"` // unsigned or chunked, streaming only
+	ChunkSize         int            `mapstructure:"chunk_size,omitempty"`
+}
+
+// signer signs every attempt over the exact bytes it sends. It runs after the authenticator so the
+// signature covers the final headers and URL.
+type signer struct {
+	config          signingConfig
+	secret          *expression.Expression
+	accessKeyID     *expression.Expression
+	secretAccessKey *expression.Expression
+	sessionToken    *expression.Expression
+	now             func() time.Time
+}
+
+func newSigner(conf signingConfig, streaming bool, exprOptions ...expr.Option) (*signer, error) {
+	var err error
+	s := &signer{config: conf, now: time.Now}
+	switch conf.Type {
+	case signingNone:
+	case signingHMAC:
+		if streaming {
+			return nil, fmt.Errorf("hmac signing needs the whole body before sending it and does not support use_streaming")
+		}
+		if (conf.Secret == "") == (conf.SecretFile == "") {
+			return nil, fmt.Errorf("exactly one of secret or secret_file is required for hmac signing")
+		}
+		if s.config.SignatureHeader == "" {
+			s.config.SignatureHeader = defaultSignatureHeader
+		}
+		if s.config.TimestampHeader == "" {
+			s.config.TimestampHeader = defaultTimestampHeader
+		}
+		if s.config.SignatureEncoding == "" {
+			s.config.SignatureEncoding = "hex"
+		}
+		if s.config.SignatureEncoding != "hex" && s.config.SignatureEncoding != "base64" {
+			return nil, fmt.Errorf("unsupported signature_encoding %s", conf.SignatureEncoding)
+		}
+		s.secret, err = expression.Compile(conf.Secret, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("secret: %w", err)
+		}
+	case signingAWSSigV4:
+		if conf.AccessKeyID == "" || conf.SecretAccessKey == "" || conf.Region == "" || conf.Service == "" {
+			return nil, fmt.Errorf("access_key_id, secret_access_key, region and service are required for aws_sigv4 signing")
+		}
+		if s.config.PayloadSigning == "" {
+			s.config.PayloadSigning = payloadUnsigned
+		}
+		if s.config.PayloadSigning != payloadUnsigned && s.config.PayloadSigning != payloadChunked {
+			return nil, fmt.Errorf("unsupported payload_signing %s", conf.PayloadSigning)
+		}
+		if s.config.ChunkSize == 0 {
+			s.config.ChunkSize = defaultAWSChunkSize
+		}
+		if s.config.ChunkSize < minAWSChunkSize {
+			return nil, fmt.Errorf("chunk_size must be at least %d bytes", minAWSChunkSize)
+		}
+		s.accessKeyID, err = expression.Compile(conf.AccessKeyID, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("access_key_id: %w", err)
+		}
+		s.secretAccessKey, err = expression.Compile(conf.SecretAccessKey, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("secret_access_key: %w", err)
+		}
+		s.sessionToken, err = expression.Compile(conf.SessionToken, exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("session_token: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported signing type %s", conf.Type)
+	}
+	return s, nil
+}
+
+// sign adds the signature of the configured type to req.
+func (s *signer) sign(req *http.Request, info *definitions.EngineFlowObject) error {
+	switch s.config.Type {
+	case signingHMAC:
+		return s.signHMAC(req, info)
+	case signingAWSSigV4:
+		return s.signAWS(req, info)
+	}
+	return nil
+}
+
+// signHMAC sets the timestamp header and an HMAC-SHA256 of "<timestamp>.<body>" as the signature header.
+func (s *signer) signHMAC(req *http.Request, info *definitions.EngineFlowObject) error {
+	secret, err := s.hmacSecret(info)
+	if err != nil {
+		return err
+	}
+	body, err := bufferedBody(req)
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	var signature string
+	if s.config.SignatureEncoding == "base64" {
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	} else {
+		signature = hex.EncodeToString(mac.Sum(nil))
+	}
+
+	req.Header.Set(s.config.TimestampHeader, timestamp)
+	req.Header.Set(s.config.SignatureHeader, s.config.SignaturePrefix+signature)
+	return nil
+}
+
+func (s *signer) hmacSecret(info *definitions.EngineFlowObject) (string, error) {
+	if s.config.SecretFile != "" {
+		// read on every request so rotated secrets are picked up
+		secret, err := os.ReadFile(s.config.SecretFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimSpace(string(secret)), nil
+	}
+	secret, err := s.secret.Evaluate(info.Metadata)
+	if err != nil {
+		return "", fmt.Errorf("failed to evaluate secret: %w", err)
+	}
+	return secret, nil
+}
+
+// bufferedBody returns the body of a request whose body is held in memory, without consuming it.
+func bufferedBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("cannot sign a streamed request body")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// readerSize returns the number of bytes left in r, if it can be known without reading it.
+func readerSize(r io.Reader) (int64, bool) {
+	switch reader := r.(type) {
+	case interface{ Len() int }:
+		return int64(reader.Len()), true
+	case io.Seeker:
+		current, err := reader.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		end, err := reader.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, false
+		}
+		_, err = reader.Seek(current, io.SeekStart)
+		if err != nil {
+			return 0, false
+		}
+		return end - current, true
+	}
+	return 0, false
+}
@@ -0,0 +1,270 @@
+package uploadhttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	awsAlgorithm          = "AWS4-HMAC-SHA256"
+	awsUnsignedPayload    = "UNSIGNED-PAYLOAD"
+	awsStreamingPayload   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	awsTimeFormat         = "20060102T150405Z"
+	awsDateFormat         = "20060102"
+	awsChunkSignatureSize = 64
+)
+
+var emptySHA256 = sha256Hex(nil)
+
+// awsSigning holds what is needed to sign a request and the chunks of its body.
+type awsSigning struct {
+	key       []byte
+	amzDate   string
+	scope     string
+	signature string
+}
+
+// signAWS signs req with AWS Signature Version 4. Buffered bodies are hashed as they are, streamed
+// ones are either sent as UNSIGNED-PAYLOAD or signed chunk by chunk.
+func (s *signer) signAWS(req *http.Request, info *definitions.EngineFlowObject) error {
+	accessKeyID, err := s.accessKeyID.Evaluate(info.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate access key id: %w", err)
+	}
+	secretAccessKey, err := s.secretAccessKey.Evaluate(info.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate secret access key: %w", err)
+	}
+	sessionToken, err := s.sessionToken.Evaluate(info.Metadata)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate session token: %w", err)
+	}
+
+	now := s.now().UTC()
+	signing := &awsSigning{
+		amzDate: now.Format(awsTimeFormat),
+		scope:   strings.Join([]string{now.Format(awsDateFormat), s.config.Region, s.config.Service, "aws4_request"}, "/"),
+	}
+	signing.key = []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{now.Format(awsDateFormat), s.config.Region, s.config.Service, "aws4_request"} {
+		signing.key = hmacSHA256(signing.key, part)
+	}
+
+	var payloadHash string
+	var chunked *guardedBody
+	body, streamed := req.Body.(*guardedBody)
+	switch {
+	case !streamed:
+		payload, err := bufferedBody(req)
+		if err != nil {
+			return err
+		}
+		payloadHash = sha256Hex(payload)
+	case s.config.PayloadSigning == payloadChunked:
+		payloadHash = awsStreamingPayload
+		chunked = body
+		encoding := "aws-chunked"
+		if existing := req.Header.Get("Content-Encoding"); existing != "" {
+			encoding += "," + existing
+		}
+		req.Header.Set("Content-Encoding", encoding)
+		if size, ok := readerSize(body.reader); ok {
+			req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(size, 10))
+			req.ContentLength = awsChunkedLength(size, int64(s.config.ChunkSize))
+		} else {
+			req.Header.Del("X-Amz-Decoded-Content-Length")
+		}
+	default:
+		payloadHash = awsUnsignedPayload
+		// S3 rejects uploads without a length, so it is sent whenever it is known
+		if size, ok := readerSize(body.reader); ok && req.ContentLength <= 0 {
+			req.ContentLength = size
+		}
+	}
+
+	req.Header.Set("X-Amz-Date", signing.amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	} else {
+		req.Header.Del("X-Amz-Security-Token")
+	}
+	req.Header.Del("Authorization")
+
+	signedHeaders, canonicalHeaders := awsCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL, s.config.Service),
+		awsCanonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		awsAlgorithm,
+		signing.amzDate,
+		signing.scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+	signing.signature = hex.EncodeToString(hmacSHA256(signing.key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsAlgorithm, accessKeyID, signing.scope, signedHeaders, signing.signature,
+	))
+
+	if chunked != nil {
+		chunked.wrap(func(r io.Reader) io.Reader {
+			return &awsChunkedReader{reader: r, signing: signing, chunkSize: s.config.ChunkSize}
+		})
+	}
+	return nil
+}
+
+// awsCanonicalHeaders returns the signed header names and the canonical headers. Host, the
+// content length and encoding and all x-amz-* headers are signed.
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	if req.ContentLength > 0 {
+		values["content-length"] = strconv.FormatInt(req.ContentLength, 10)
+	}
+	for key, headerValues := range req.Header {
+		name := strings.ToLower(key)
+		if name != "content-encoding" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, 0, len(headerValues))
+		for _, value := range headerValues {
+			trimmed = append(trimmed, strings.Join(strings.Fields(value), " "))
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// awsCanonicalURI returns the path as the service encodes it when checking the signature. S3 encodes
+// the path once, all the other services encode the path that was sent, which is already encoded,
+// once more.
+func awsCanonicalURI(u *url.URL, service string) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if service == "s3" {
+			if unescaped, err := url.PathUnescape(segment); err == nil {
+				segment = unescaped
+			}
+		}
+		segments[i] = awsEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func awsCanonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// awsChunkedLength returns the length of a body of size bytes once encoded in chunks of chunkSize.
+func awsChunkedLength(size, chunkSize int64) int64 {
+	chunkLength := func(n int64) int64 {
+		return int64(len(strconv.FormatInt(n, 16))+len(";chunk-signature=")+awsChunkSignatureSize+2) + n + 2
+	}
+	length := (size / chunkSize) * chunkLength(chunkSize)
+	if rest := size % chunkSize; rest > 0 {
+		length += chunkLength(rest)
+	}
+	return length + chunkLength(0)
+}
+
+// awsChunkedReader encodes the body in aws-chunked chunks, each signed over the signature of the one
+// before it, starting with the signature of the request. The body ends with a signed empty chunk.
+type awsChunkedReader struct {
+	reader    io.Reader
+	signing   *awsSigning
+	chunkSize int
+	chunk     []byte
+	pending   bytes.Buffer
+	done      bool
+}
+
+func (c *awsChunkedReader) Read(p []byte) (int, error) {
+	for c.pending.Len() == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if c.chunk == nil {
+			c.chunk = make([]byte, c.chunkSize)
+		}
+		n, err := io.ReadFull(c.reader, c.chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		if n > 0 {
+			c.writeChunk(c.chunk[:n])
+		}
+		if err != nil {
+			c.writeChunk(nil)
+			c.done = true
+		}
+	}
+	return c.pending.Read(p)
+}
+
+func (c *awsChunkedReader) writeChunk(data []byte) {
+	hash := sha256.Sum256(data)
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256-PAYLOAD",
+		c.signing.amzDate,
+		c.signing.scope,
+		c.signing.signature,
+		emptySHA256,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+	c.signing.signature = hex.EncodeToString(hmacSHA256(c.signing.key, stringToSign))
+	fmt.Fprintf(&c.pending, "%x;chunk-signature=%s\r\n", len(data), c.signing.signature)
+	c.pending.Write(data)
+	c.pending.WriteString("\r\n")
+}
@@ -1,7 +1,6 @@
 package uploadhttp
 
 import (
-	"bytes"
 	"encoding/base64"
 	"fmt"
 	"github.com/go-streamline/interfaces/definitions"
@@ -13,60 +12,140 @@ import (
 
 func (h *UploadHTTP) generateStreamingRequest(
 	log *logrus.Logger,
-	url string,
+	method, url string,
 	info *definitions.EngineFlowObject,
 	reader io.Reader,
 ) (*http.Request, error) {
+	var contentType string
+	switch h.config.Type {
+	case sendFileRaw:
+		log.Debugf("streaming file as raw body")
+		var err error
+		contentType, err = evaluateAndLog(log, info, h.compiled.contentType, "content type")
+		if err != nil {
+			return nil, err
+		}
+		if h.config.Compression == compressionNone {
+			req, err := http.NewRequest(method, url, newGuardedBody(reader, nil))
+			if err != nil {
+				log.WithError(err).Errorf("failed to create HTTP request")
+				return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+			}
+			// a known length keeps the upload from being chunked, which servers such as S3 presigned
+			// URLs reject
+			if size, ok := readerSize(reader); ok {
+				req.ContentLength = size
+				if size == 0 {
+					req.Body = http.NoBody
+				}
+			}
+			req.Header.Set("Content-Type", contentType)
+			return req, nil
+		}
+	case sendFileNone:
+		log.Debugf("sending request without body")
+		req, err := http.NewRequest(method, url, nil)
+		if err != nil {
+			log.WithError(err).Errorf("failed to create HTTP request")
+			return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+		}
+		return req, nil
+	}
+
 	pr, pw := io.Pipe()
-	req, err := http.NewRequest("POST", url, pr)
+	body := newGuardedBody(pr, pr)
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		log.WithError(err).Errorf("failed to create HTTP request")
 		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
+	bodyWriter, err := newCompressor(h.config.Compression, h.config.CompressionLevel, pw)
+	if err != nil {
+		log.WithError(err).Errorf("failed to create compressor")
+		return nil, fmt.Errorf("failed to create compressor: %w", err)
+	}
+	if h.config.Compression != compressionNone {
+		req.Header.Set("Content-Encoding", string(h.config.Compression))
+	}
+	// finish flushes the compressor and ends the body, failing the request if writing it failed
+	finish := func(err error) {
+		if err == nil {
+			err = bodyWriter.Close()
+		}
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.Close()
+	}
+
 	switch h.config.Type {
 	case sendFileMultipart:
 		log.Debugf("sending file as multipart with streaming")
-		writer := multipart.NewWriter(pw)
-		contentType := writer.FormDataContentType()
-		req.Header.Set("Content-Type", contentType)
+		writer := multipart.NewWriter(bodyWriter)
+		contentType = writer.FormDataContentType()
 		log.Debugf("generating multipart with content type %s", contentType)
+		body.writers.Add(1)
 		go func() {
-			err := h.generateMultipart(log, info, writer, reader)
-			if err != nil {
-				pw.CloseWithError(err)
-				return
-			}
-
-			log.Debugf("setting content type as %s", contentType)
-			pw.Close()
+			defer body.writers.Done()
+			finish(h.generateMultipart(log, info, writer, reader))
 		}()
 	case sendFileBase64:
-		log.Debugf("Sending file as base64")
-		var base64Content bytes.Buffer
-		base64Writer := base64.NewEncoder(base64.StdEncoding, &base64Content)
-		defer base64Writer.Close()
-		log.Debugf("copying file to base64")
-		_, err = io.Copy(base64Writer, reader)
-		if err != nil {
-			log.WithError(err).Errorf("failed to copy file to base64")
-			return nil, fmt.Errorf("failed to copy file to base64: %w", err)
-		}
-		log.Debugf("closing base64 writer")
-		formattedContent, err := h.formatBase64Content(base64Content.String(), info)
+		log.Debugf("Sending file as base64 with streaming")
+		prefix, suffix, err := h.splitBase64Format(info)
 		if err != nil {
 			log.WithError(err).Errorf("failed to format base64 content")
 			return nil, fmt.Errorf("failed to format base64 content: %w", err)
 		}
+		body.writers.Add(1)
+		go func() {
+			defer body.writers.Done()
+			err := writeBase64Body(bodyWriter, prefix, suffix, reader)
+			if err != nil {
+				log.WithError(err).Errorf("failed to write base64 content")
+			}
+			finish(err)
+		}()
+	case sendFileRaw:
+		log.Debugf("compressing raw body with %s", h.config.Compression)
+		body.writers.Add(1)
 		go func() {
-			_, err = pw.Write([]byte(formattedContent))
+			defer body.writers.Done()
+			_, err := io.Copy(bodyWriter, reader)
 			if err != nil {
-				pw.CloseWithError(err)
-				log.WithError(err).Errorf("failed to write formatted content")
+				log.WithError(err).Errorf("failed to copy file to request body")
 			}
-			pw.Close()
+			finish(err)
 		}()
 	}
 
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
 	return req, nil
 }
+
+// writeBase64Body writes prefix, the base64 encoded contents of reader and suffix to writer,
+// without ever holding more than a chunk of the contents in memory.
+func writeBase64Body(writer io.Writer, prefix, suffix string, reader io.Reader) error {
+	_, err := io.WriteString(writer, prefix)
+	if err != nil {
+		return fmt.Errorf("failed to write base64 prefix: %w", err)
+	}
+	base64Writer := base64.NewEncoder(base64.StdEncoding, writer)
+	_, err = io.Copy(base64Writer, reader)
+	if err != nil {
+		return fmt.Errorf("failed to copy file to base64: %w", err)
+	}
+	// closing flushes the last partial block of the encoding
+	err = base64Writer.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close base64 writer: %w", err)
+	}
+	_, err = io.WriteString(writer, suffix)
+	if err != nil {
+		return fmt.Errorf("failed to write base64 suffix: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,423 @@
+package uploadhttp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	tusVersion          = "1.0.0"
+	tusStatePrefix      = "tus:"
+	defaultTusChunkSize = 5 * 1024 * 1024
+)
+
+type tusConfig struct {
+	UploadKey string            `mapstructure:"upload_key"`           // identifies the flow file across executions
+	ChunkSize int               `mapstructure:"chunk_size,omitempty"` // in bytes
+	Metadata  map[string]string `mapstructure:"metadata,omitempty"`   // Upload-Metadata key -> value
+}
+
+type compiledTus struct {
+	uploadKey *expression.Expression
+	metadata  []compiledTusMetadata
+}
+
+type compiledTusMetadata struct {
+	key   string
+	value *expression.Expression
+}
+
+func (h *UploadHTTP) compileTus() (*compiledTus, error) {
+	var err error
+	compiled := &compiledTus{}
+	compiled.uploadKey, err = expression.Compile(h.config.Tus.UploadKey, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("tus upload_key: %w", err)
+	}
+	keys := make([]string, 0, len(h.config.Tus.Metadata))
+	for key := range h.config.Tus.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := expression.Compile(h.config.Tus.Metadata[key], h.exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("tus metadata %s: %w", key, err)
+		}
+		compiled.metadata = append(compiled.metadata, compiledTusMetadata{key: key, value: value})
+	}
+	return compiled, nil
+}
+
+// tusUpload is the state of a single upload of the flow file contents.
+type tusUpload struct {
+	h         *UploadHTTP
+	log       *logrus.Logger
+	info      *definitions.EngineFlowObject
+	headers   http.Header
+	url       string
+	size      int64
+	sizeKnown bool
+	attempts  int
+}
+
+// sendTus uploads the contents with the tus resumable upload protocol. The URL of the upload is kept
+// in the state, so a flow file that failed is resumed from the last acknowledged offset.
+func (h *UploadHTTP) sendTus(
+	log *logrus.Logger,
+	info *definitions.EngineFlowObject,
+	source *contentSource,
+	url string,
+	headers http.Header,
+) (*http.Response, int, error) {
+	uploadKey, err := evaluateAndLog(log, info, h.compiled.tus.uploadKey, "tus upload key")
+	if err != nil {
+		return nil, 0, err
+	}
+	reader, err := source.next()
+	if err != nil {
+		log.WithError(err).Errorf("failed to read file")
+		return nil, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	upload := &tusUpload{h: h, log: log, info: info, headers: headers}
+	upload.size, upload.sizeKnown = readerSize(reader)
+
+	upload.url, err = h.loadTusUpload(uploadKey)
+	if err != nil {
+		log.WithError(err).Errorf("failed to load tus upload state")
+		return nil, 0, fmt.Errorf("failed to load tus upload state: %w", err)
+	}
+
+	offset := int64(-1)
+	var resp *http.Response
+	if upload.url != "" {
+		log.Debugf("resuming tus upload %s", upload.url)
+		resp, offset, err = upload.head()
+		if err != nil {
+			return nil, upload.attempts, err
+		}
+		if offset < 0 {
+			log.Infof("tus upload %s can no longer be resumed, starting a new one", upload.url)
+		}
+	}
+	if offset < 0 {
+		err = upload.create(url)
+		if err != nil {
+			return nil, upload.attempts, err
+		}
+		err = h.storeTusUpload(uploadKey, upload.url)
+		if err != nil {
+			log.WithError(err).Errorf("failed to store tus upload state")
+			return nil, upload.attempts, fmt.Errorf("failed to store tus upload state: %w", err)
+		}
+		offset = 0
+	}
+
+	if resp != nil && upload.sizeKnown && offset >= upload.size {
+		log.Debugf("tus upload %s is already complete", upload.url)
+	} else {
+		if resp != nil {
+			_ = resp.Body.Close()
+		}
+		reader, err = skip(reader, offset)
+		if err != nil {
+			log.WithError(err).Errorf("failed to skip to offset %d", offset)
+			return nil, upload.attempts, fmt.Errorf("failed to skip to offset %d: %w", offset, err)
+		}
+		resp, err = upload.patchAll(bufio.NewReader(reader), offset)
+		if err != nil {
+			return nil, upload.attempts, err
+		}
+	}
+
+	err = h.storeTusUpload(uploadKey, "")
+	if err != nil {
+		_ = resp.Body.Close()
+		log.WithError(err).Errorf("failed to clear tus upload state")
+		return nil, upload.attempts, fmt.Errorf("failed to clear tus upload state: %w", err)
+	}
+	info.Metadata["UploadHTTP.UploadURL"] = upload.url
+	return resp, upload.attempts, nil
+}
+
+// head returns the offset of the upload, or -1 if the server no longer knows it or its length
+// does not match the contents anymore.
+func (u *tusUpload) head() (*http.Response, int64, error) {
+	resp, err := u.send("HEAD", u.url, nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone || resp.StatusCode == http.StatusForbidden {
+		_ = resp.Body.Close()
+		return nil, -1, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = resp.Body.Close()
+		u.log.Errorf("failed to get the offset of tus upload: %s", resp.Status)
+		return nil, 0, fmt.Errorf("failed to get the offset of tus upload: %s", resp.Status)
+	}
+	offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, 0, fmt.Errorf("invalid Upload-Offset %q", resp.Header.Get("Upload-Offset"))
+	}
+	if length := resp.Header.Get("Upload-Length"); length != "" && u.sizeKnown && length != strconv.FormatInt(u.size, 10) {
+		_ = resp.Body.Close()
+		u.log.Warnf("tus upload %s has a length of %s but the contents have %d bytes", u.url, length, u.size)
+		return nil, -1, nil
+	}
+	return resp, offset, nil
+}
+
+func (u *tusUpload) create(url string) error {
+	headers := http.Header{}
+	if u.sizeKnown {
+		headers.Set("Upload-Length", strconv.FormatInt(u.size, 10))
+	} else {
+		headers.Set("Upload-Defer-Length", "1")
+	}
+	var metadata []string
+	for _, field := range u.h.compiled.tus.metadata {
+		value, err := evaluateAndLog(u.log, u.info, field.value, "tus metadata "+field.key)
+		if err != nil {
+			return err
+		}
+		metadata = append(metadata, field.key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	if len(metadata) > 0 {
+		headers.Set("Upload-Metadata", strings.Join(metadata, ","))
+	}
+
+	resp, err := u.send(http.MethodPost, url, headers, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		u.log.Errorf("failed to create tus upload: %s", resp.Status)
+		return fmt.Errorf("failed to create tus upload: %s", resp.Status)
+	}
+	base, err := neturl.Parse(url)
+	if err != nil {
+		return fmt.Errorf("invalid url: %w", err)
+	}
+	location, err := base.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.Header.Get("Location") == "" {
+		return fmt.Errorf("invalid Location %q of created tus upload", resp.Header.Get("Location"))
+	}
+	u.url = location.String()
+	u.log.Debugf("created tus upload %s", u.h.auth.RedactURL(location))
+	return nil
+}
+
+// patchAll sends the contents from offset on in chunks, and returns the response to the last one.
+// A chunk that fails is resumed from the offset the server reports.
+func (u *tusUpload) patchAll(reader *bufio.Reader, offset int64) (*http.Response, error) {
+	chunk := make([]byte, u.h.config.Tus.ChunkSize)
+	for {
+		n, err := io.ReadFull(reader, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			u.log.WithError(err).Errorf("failed to read file")
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+		last := err != nil
+		if !last {
+			_, err = reader.Peek(1)
+			last = err == io.EOF
+		}
+
+		resp, err := u.patchChunk(chunk[:n], offset, last)
+		if err != nil {
+			return nil, err
+		}
+		offset += int64(n)
+		if last {
+			return resp, nil
+		}
+		_ = resp.Body.Close()
+	}
+}
+
+func (u *tusUpload) patchChunk(chunk []byte, chunkOffset int64, last bool) (*http.Response, error) {
+	sent := int64(0)
+	for attempt := 1; ; attempt++ {
+		headers := http.Header{}
+		headers.Set("Content-Type", "application/offset+octet-stream")
+		headers.Set("Upload-Offset", strconv.FormatInt(chunkOffset+sent, 10))
+		if last && !u.sizeKnown {
+			headers.Set("Upload-Length", strconv.FormatInt(chunkOffset+int64(len(chunk)), 10))
+		}
+
+		resp, err := u.sendOnce(http.MethodPatch, u.url, headers, chunk[sent:])
+		var failure error
+		switch {
+		case err != nil:
+			if !u.h.retry.shouldRetryError(err) {
+				u.log.WithError(err).Errorf("failed to send tus chunk")
+				return nil, fmt.Errorf("failed to send tus chunk: %w", err)
+			}
+			failure = err
+		case resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusOK:
+			offset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+			if err != nil || offset < chunkOffset+sent || offset > chunkOffset+int64(len(chunk)) {
+				_ = resp.Body.Close()
+				return nil, fmt.Errorf("invalid Upload-Offset %q", resp.Header.Get("Upload-Offset"))
+			}
+			sent = offset - chunkOffset
+			if sent == int64(len(chunk)) {
+				return resp, nil
+			}
+			// the server accepted only part of the chunk, which is progress and not a failure
+			_ = resp.Body.Close()
+			attempt = 0
+			continue
+		case u.h.retry.shouldRetryStatus(resp.StatusCode) || resp.StatusCode == http.StatusConflict || resp.StatusCode >= 500:
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			failure = fmt.Errorf("received %s", resp.Status)
+		default:
+			_ = resp.Body.Close()
+			u.log.Errorf("failed to send tus chunk: %s", resp.Status)
+			return nil, fmt.Errorf("failed to send tus chunk: %s", resp.Status)
+		}
+
+		if attempt >= u.h.retry.maxAttempts {
+			u.log.WithError(failure).Errorf("failed to send tus chunk at offset %d", chunkOffset+sent)
+			return nil, fmt.Errorf("failed to send tus chunk at offset %d: %w", chunkOffset+sent, failure)
+		}
+		delay := u.h.retry.backoff(attempt, nil)
+		u.log.WithError(failure).Warnf("tus chunk at offset %d failed, resuming in %s", chunkOffset+sent, delay)
+		u.h.retry.sleep(delay)
+
+		// the server may have stored part of the failed chunk, continue from where it stopped
+		headResp, offset, err := u.head()
+		if err != nil {
+			return nil, err
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("tus upload %s is gone", u.url)
+		}
+		_ = headResp.Body.Close()
+		if offset < chunkOffset || offset > chunkOffset+int64(len(chunk)) {
+			return nil, fmt.Errorf("tus upload is at offset %d, outside of the chunk at %d", offset, chunkOffset)
+		}
+		sent = offset - chunkOffset
+		if sent == int64(len(chunk)) && !last {
+			return headResp, nil
+		}
+	}
+}
+
+// send sends a request of the upload, retrying it like any other request.
+func (u *tusUpload) send(method, url string, headers http.Header, body []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := u.sendOnce(method, url, headers, body)
+		if err != nil {
+			if attempt >= u.h.retry.maxAttempts || !u.h.retry.shouldRetryError(err) {
+				u.log.WithError(err).Errorf("failed to send HTTP request")
+				return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+			}
+			delay := u.h.retry.backoff(attempt, nil)
+			u.log.WithError(err).Warnf("attempt %d of %d failed, retrying in %s", attempt, u.h.retry.maxAttempts, delay)
+			u.h.retry.sleep(delay)
+			continue
+		}
+		if attempt < u.h.retry.maxAttempts && u.h.retry.shouldRetryStatus(resp.StatusCode) {
+			delay := u.h.retry.backoff(attempt, resp)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			u.log.Warnf("attempt %d of %d received %s, retrying in %s", attempt, u.h.retry.maxAttempts, resp.Status, delay)
+			u.h.retry.sleep(delay)
+			continue
+		}
+		return resp, nil
+	}
+}
+
+func (u *tusUpload) sendOnce(method, url string, headers http.Header, body []byte) (*http.Response, error) {
+	u.attempts++
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	for key, values := range u.headers {
+		req.Header[key] = values
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	req.Header.Set("Tus-Resumable", tusVersion)
+
+	err = u.h.auth.Apply(req, u.info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	err = u.h.signer.sign(req, u.info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign request: %w", err)
+	}
+	u.log.Debugf("sending %s %s with headers %v", req.Method, u.h.auth.RedactURL(req.URL), u.h.auth.RedactHeaders(req.Header))
+
+	resp, err := u.h.do(u.log, req)
+	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = u.h.auth.RedactURL(req.URL)
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// skip advances reader by offset bytes, seeking when it can.
+func skip(reader io.Reader, offset int64) (io.Reader, error) {
+	if offset == 0 {
+		return reader, nil
+	}
+	if seeker, ok := reader.(io.Seeker); ok {
+		_, err := seeker.Seek(offset, io.SeekStart)
+		return reader, err
+	}
+	_, err := io.CopyN(io.Discard, reader, offset)
+	return reader, err
+}
+
+func (h *UploadHTTP) loadTusUpload(uploadKey string) (string, error) {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	state, err := h.localState()
+	if err != nil {
+		return "", err
+	}
+	url, _ := state[tusStatePrefix+uploadKey].(string)
+	return url, nil
+}
+
+// storeTusUpload keeps the URL of the upload of uploadKey, or forgets it if url is empty.
+func (h *UploadHTTP) storeTusUpload(uploadKey, url string) error {
+	h.stateMu.Lock()
+	defer h.stateMu.Unlock()
+	state, err := h.localState()
+	if err != nil {
+		return err
+	}
+	if url == "" {
+		delete(state, tusStatePrefix+uploadKey)
+	} else {
+		state[tusStatePrefix+uploadKey] = url
+	}
+	return h.stateManager.SetState(definitions.StateTypeLocal, state)
+}
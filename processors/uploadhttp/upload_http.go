@@ -2,18 +2,38 @@ package uploadhttp
 
 import (
 	"fmt"
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
 	"github.com/go-streamline/interfaces/utils"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"io"
 	"mime/multipart"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 )
 
 type UploadHTTP struct {
 	definitions.BaseProcessor
-	config *config
-	client utils.HTTPClient
+	config         *config
+	compiled       *compiledConfig
+	retry          *retryPolicy
+	auth           *httpclient.Authenticator
+	signer         *signer
+	guards         *hostGuards
+	idempotencyTTL time.Duration
+	transport      *http.Transport
+	client         utils.HTTPClient
+	stateManager   definitions.StateManager
+	stateMu        sync.Mutex
+	state          map[string]any // the local state, see localState
+	// idempotencySwept is when expired idempotency records were last dropped from the state
+	idempotencySwept time.Time
+	exprOptions      []expr.Option
 }
 
 type sendFileType string
@@ -21,28 +41,70 @@ type sendFileType string
 const (
 	sendFileMultipart sendFileType = "multipart"
 	sendFileBase64    sendFileType = "base64"
+	sendFileRaw       sendFileType = "raw"
+	sendFileNone      sendFileType = "none"
+	sendFileTus       sendFileType = "tus"
 )
 
 type config struct {
-	URL                     string            `mapstructure:"url"`
-	ExtraHeaders            map[string]string `mapstructure:"extra_headers,omitempty"`
-	Type                    sendFileType      `mapstructure:"type"`
-	PutResponseAsContents   bool              `mapstructure:"put_response_as_contents"`
-	MultipartFieldName      string            `mapstructure:"multipart_field_name,omitempty"`
-	MultipartFilename       string            `mapstructure:"multipart_filename,omitempty"`
-	MultipartContentType    string            `mapstructure:"multipart_content_type,omitempty"`
-	Base64BodyFormat        string            `mapstructure:"base64_body_format,omitempty"`
-	WriteResponseToMetadata bool              `mapstructure:"write_response_to_metadata,omitempty"`
-	UseStreaming            bool              `mapstructure:"use_streaming,omitempty"`
+	URL                     string                     `mapstructure:"url"`
+	Method                  string                     `mapstructure:"method,omitempty"`
+	ExtraHeaders            map[string]string          `mapstructure:"extra_headers,omitempty"`
+	Type                    sendFileType               `mapstructure:"type"`
+	PutResponseAsContents   bool                       `mapstructure:"put_response_as_contents"`
+	MultipartFieldName      string                     `mapstructure:"multipart_field_name,omitempty"`
+	MultipartFilename       string                     `mapstructure:"multipart_filename,omitempty"`
+	MultipartContentType    string                     `mapstructure:"multipart_content_type,omitempty"`
+	MultipartFields         map[string]interface{}     `mapstructure:"multipart_fields,omitempty"`        // field name -> value or {value, content_type}
+	MultipartMetadataPart   string                     `mapstructure:"multipart_metadata_part,omitempty"` // name of a JSON part holding the metadata
+	MultipartMetadataKeys   []string                   `mapstructure:"multipart_metadata_keys,omitempty"` // metadata keys of that part, all if empty
+	Base64BodyFormat        string                     `mapstructure:"base64_body_format,omitempty"`
+	ContentType             string                     `mapstructure:"content_type,omitempty"`
+	WriteResponseToMetadata bool                       `mapstructure:"write_response_to_metadata,omitempty"`
+	UseStreaming            bool                       `mapstructure:"use_streaming,omitempty"`
+	Compression             compressionType            `mapstructure:"compression,omitempty"`                // gzip or zstd, the body is sent uncompressed if not set
+	CompressionLevel        int                        `mapstructure:"compression_level,omitempty"`          // the algorithm's default if not set
+	MaxResponseBody         int64                      `mapstructure:"max_response_body,omitempty"`          // in bytes, unlimited if not set
+	MaxResponseMetadataBody int                        `mapstructure:"max_response_metadata_body,omitempty"` // in bytes
+	ResponseExtract         map[string]string          `mapstructure:"response_extract,omitempty"`           // metadata key -> JSONPath or expr
+	ResponseHeaders         map[string]string          `mapstructure:"response_headers,omitempty"`           // metadata key -> header name
+	Retry                   retryConfig                `mapstructure:"retry,omitempty"`
+	Auth                    httpclient.AuthConfig      `mapstructure:"auth,omitempty"`
+	Signing                 signingConfig              `mapstructure:"signing,omitempty"`
+	Tus                     tusConfig                  `mapstructure:"tus,omitempty"`
+	IdempotencyKey          string                     `mapstructure:"idempotency_key,omitempty"`
+	IdempotencyHeader       string                     `mapstructure:"idempotency_header,omitempty"`
+	IdempotencyTTL          string                     `mapstructure:"idempotency_ttl,omitempty"`
+	IdempotencyMaxBody      int                        `mapstructure:"idempotency_max_body,omitempty"` // in bytes, larger bodies are not stored
+	CircuitBreaker          circuitBreakerConfig       `mapstructure:"circuit_breaker,omitempty"`
+	RateLimit               rateLimitConfig            `mapstructure:"rate_limit,omitempty"`
+	TLS                     httpclient.TLSConfig       `mapstructure:"tls,omitempty"`
+	Transport               httpclient.TransportConfig `mapstructure:"transport,omitempty"`
+}
+
+// compiledConfig holds the expressions of config, compiled once in SetConfig.
+type compiledConfig struct {
+	url                *expression.Expression
+	method             *expression.Expression
+	contentType        *expression.Expression
+	headers            []expression.Header
+	base64BodyFormat   *expression.Expression
+	multipartFieldName *expression.Expression
+	multipartFilename  *expression.Expression
+	extract            []responseExtractor
+	multipartFields    []compiledMultipartField
+	tus                *compiledTus
+	idempotencyKey     *expression.Expression
 }
 
 type bas64FormatTemplate struct {
 	Base64Contents string
 }
 
-func NewUploadHTTP() definitions.Processor {
+func NewUploadHTTP(stateManager definitions.StateManager, exprOptions ...expr.Option) definitions.Processor {
 	return &UploadHTTP{
-		client: utils.NewHTTPClient(),
+		stateManager: stateManager,
+		exprOptions:  exprOptions,
 	}
 }
 
@@ -56,6 +118,19 @@ func (h *UploadHTTP) SetConfig(conf map[string]interface{}) error {
 	if h.config.Type == "" {
 		h.config.Type = sendFileMultipart
 	}
+	switch h.config.Type {
+	case sendFileMultipart, sendFileBase64, sendFileRaw, sendFileNone, sendFileTus:
+	default:
+		return fmt.Errorf("unsupported type %s", h.config.Type)
+	}
+	if h.config.Method == "" {
+		h.config.Method = http.MethodPost
+	}
+	err = validateCompression(h.config.Compression, h.config.CompressionLevel)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid compression config")
+		return fmt.Errorf("invalid compression config: %w", err)
+	}
 	if h.config.MultipartFieldName == "" && h.config.Type == sendFileMultipart {
 		return fmt.Errorf("multipart field name is required for multipart type")
 	}
@@ -63,6 +138,41 @@ func (h *UploadHTTP) SetConfig(conf map[string]interface{}) error {
 		return fmt.Errorf("base64 format is required for base64 type")
 	}
 
+	if h.config.Type == sendFileTus {
+		if h.config.Tus.UploadKey == "" {
+			return fmt.Errorf("tus upload_key is required for tus type")
+		}
+		if h.stateManager == nil {
+			return fmt.Errorf("tus type requires a state manager")
+		}
+		if h.config.Compression != compressionNone {
+			return fmt.Errorf("compression is not supported with tus type")
+		}
+		if h.config.Tus.ChunkSize <= 0 {
+			h.config.Tus.ChunkSize = defaultTusChunkSize
+		}
+	}
+
+	if h.config.IdempotencyKey != "" {
+		if h.stateManager == nil {
+			return fmt.Errorf("idempotency_key requires a state manager")
+		}
+		if h.config.IdempotencyHeader == "" {
+			h.config.IdempotencyHeader = defaultIdempotencyHeader
+		}
+		h.idempotencyTTL, err = httpclient.ParseDuration(h.config.IdempotencyTTL, defaultIdempotencyTTL)
+		if err != nil {
+			logrus.WithError(err).Errorf("invalid idempotency_ttl")
+			return fmt.Errorf("invalid idempotency_ttl: %w", err)
+		}
+		if h.config.IdempotencyMaxBody < 0 {
+			return fmt.Errorf("idempotency_max_body must not be negative")
+		}
+		if h.config.IdempotencyMaxBody == 0 {
+			h.config.IdempotencyMaxBody = defaultIdempotencyMaxBody
+		}
+	}
+
 	if h.config.ExtraHeaders == nil || len(h.config.ExtraHeaders) == 0 {
 		h.config.ExtraHeaders = make(map[string]string)
 	}
@@ -74,15 +184,119 @@ func (h *UploadHTTP) SetConfig(conf map[string]interface{}) error {
 	if h.config.MultipartContentType == "" {
 		h.config.MultipartContentType = "application/octet-stream"
 	}
+
+	if h.config.MaxResponseMetadataBody <= 0 {
+		h.config.MaxResponseMetadataBody = defaultMaxResponseMetadataBody
+	}
+
+	if h.config.ContentType == "" && h.config.Type == sendFileRaw {
+		h.config.ContentType = "application/octet-stream"
+	}
+
+	h.compiled, err = h.compileConfig()
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to compile config expressions")
+		return fmt.Errorf("failed to compile config expressions: %w", err)
+	}
+
+	h.retry, err = newRetryPolicy(h.config.Retry)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid retry config")
+		return fmt.Errorf("invalid retry config: %w", err)
+	}
+
+	h.guards, err = newHostGuards(h.config.CircuitBreaker, h.config.RateLimit)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid circuit breaker or rate limit config")
+		return fmt.Errorf("invalid circuit breaker or rate limit config: %w", err)
+	}
+
+	client, transport, ctx, err := httpclient.Configure(h.client, h.transport, h.config.Transport, h.config.TLS)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to create HTTP client")
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	h.client, h.transport = client, transport
+
+	h.auth, err = httpclient.NewAuthenticator(ctx, h.config.Auth, h.exprOptions...)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid auth config")
+		return fmt.Errorf("invalid auth config: %w", err)
+	}
+
+	if h.config.Signing.Type == signingAWSSigV4 && h.config.Auth.Type != httpclient.AuthNone && h.config.Auth.Type != httpclient.AuthAPIKey {
+		err = fmt.Errorf("aws_sigv4 signing sets the Authorization header and cannot be combined with %s auth", h.config.Auth.Type)
+		logrus.WithError(err).Errorf("invalid signing config")
+		return fmt.Errorf("invalid signing config: %w", err)
+	}
+	h.signer, err = newSigner(h.config.Signing, h.config.UseStreaming && h.config.Type != sendFileNone, h.exprOptions...)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid signing config")
+		return fmt.Errorf("invalid signing config: %w", err)
+	}
 	return nil
 }
 
+func (h *UploadHTTP) compileConfig() (*compiledConfig, error) {
+	var err error
+	compiled := &compiledConfig{}
+	compiled.url, err = expression.Compile(h.config.URL, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	compiled.method, err = expression.Compile(h.config.Method, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("method: %w", err)
+	}
+	compiled.contentType, err = expression.Compile(h.config.ContentType, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("content_type: %w", err)
+	}
+	compiled.base64BodyFormat, err = expression.Compile(h.config.Base64BodyFormat, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("base64_body_format: %w", err)
+	}
+	compiled.multipartFieldName, err = expression.Compile(h.config.MultipartFieldName, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("multipart_field_name: %w", err)
+	}
+	compiled.multipartFilename, err = expression.Compile(h.config.MultipartFilename, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("multipart_filename: %w", err)
+	}
+	compiled.headers, err = expression.CompileHeaders(h.config.ExtraHeaders, h.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("extra_headers %w", err)
+	}
+	compiled.extract, err = compileResponseExtract(h.config.ResponseExtract, h.exprOptions...)
+	if err != nil {
+		return nil, err
+	}
+	compiled.multipartFields, err = h.compileMultipartFields(h.config.MultipartFields)
+	if err != nil {
+		return nil, err
+	}
+	if h.config.IdempotencyKey != "" {
+		compiled.idempotencyKey, err = expression.Compile(h.config.IdempotencyKey, h.exprOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("idempotency_key: %w", err)
+		}
+	}
+	if h.config.Type == sendFileTus {
+		compiled.tus, err = h.compileTus()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
 func (*UploadHTTP) Name() string {
 	return "UploadHTTP"
 }
 
 func (h *UploadHTTP) formatBase64Content(base64Content string, info *definitions.EngineFlowObject) (string, error) {
-	base64Format, err := info.EvaluateExpression(h.config.Base64BodyFormat)
+	base64Format, err := h.compiled.base64BodyFormat.Evaluate(info.Metadata)
 	if err != nil {
 		return "", fmt.Errorf("failed to evaluate base64 format: %w", err)
 	}
@@ -95,7 +309,25 @@ func (h *UploadHTTP) formatBase64Content(base64Content string, info *definitions
 	return formattedContent, nil
 }
 
+// splitBase64Format renders the base64 body format around a marker in place of the contents, and
+// returns the parts before and after it so the contents can be streamed in between.
+func (h *UploadHTTP) splitBase64Format(info *definitions.EngineFlowObject) (string, string, error) {
+	marker := uuid.New().String()
+	formatted, err := h.formatBase64Content(marker, info)
+	if err != nil {
+		return "", "", err
+	}
+	parts := strings.Split(formatted, marker)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("base64 format must reference .Base64Contents exactly once when streaming, found %d", len(parts)-1)
+	}
+	return parts[0], parts[1], nil
+}
+
 func (h *UploadHTTP) Close() error {
+	if h.transport != nil {
+		h.transport.CloseIdleConnections()
+	}
 	return nil
 }
 
@@ -104,85 +336,96 @@ func (h *UploadHTTP) Execute(
 	fileHandler definitions.ProcessorFileHandler,
 	log *logrus.Logger,
 ) (*definitions.EngineFlowObject, error) {
-	reader, err := fileHandler.Read()
-	if err != nil {
-		log.WithError(err).Errorf("failed to read file")
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
-	url := h.config.URL
-	url, err = info.EvaluateExpression(url)
+	url, err := h.compiled.url.Evaluate(info.Metadata)
 	if err != nil {
 		log.WithError(err).Errorf("failed to evaluate URL")
 		return nil, fmt.Errorf("failed to evaluate URL: %w", err)
 	}
 
-	var req *http.Request
+	method, err := evaluateAndLog(log, info, h.compiled.method, "method")
+	if err != nil {
+		return nil, err
+	}
+	method = strings.ToUpper(method)
 
-	if h.config.UseStreaming {
-		req, err = h.generateStreamingRequest(log, url, info, reader)
+	headers := make(http.Header)
+	for _, header := range h.compiled.headers {
+		key, value, err := header.Evaluate(info.Metadata)
 		if err != nil {
+			log.WithError(err).Errorf("failed to evaluate header")
 			return nil, err
 		}
-	} else {
-		req, err = h.generateMemoryLoaderRequest(log, url, info, reader)
+		headers.Set(key, value)
+	}
+	if headers.Get("Accept-Encoding") == "" {
+		headers.Set("Accept-Encoding", acceptedEncodings)
+	}
+
+	var idempotencyKey string
+	if h.compiled.idempotencyKey != nil {
+		idempotencyKey, err = evaluateAndLog(log, info, h.compiled.idempotencyKey, "idempotency key")
 		if err != nil {
 			return nil, err
 		}
 	}
-
-	for key, value := range h.config.ExtraHeaders {
-		key, err = info.EvaluateExpression(key)
+	if idempotencyKey != "" {
+		record, err := h.loadIdempotencyRecord(idempotencyKey)
 		if err != nil {
-			log.WithError(err).Errorf("failed to evaluate header key")
-			return nil, fmt.Errorf("failed to evaluate header key: %w", err)
+			log.WithError(err).Errorf("failed to load idempotency record")
+			return nil, fmt.Errorf("failed to load idempotency record: %w", err)
 		}
-		value, err = info.EvaluateExpression(value)
-		if err != nil {
-			log.WithError(err).Errorf("failed to evaluate header value")
-			return nil, fmt.Errorf("failed to evaluate header value: %w", err)
+		if record != nil && record.truncated && (h.config.PutResponseAsContents || len(h.compiled.extract) > 0) {
+			// replaying without the body would leave the contents empty or break the extraction, so
+			// the request is sent again, with the key letting the server answer it idempotently
+			log.Infof("idempotency key %s already succeeded, but its response body was too large to store, sending the request again", idempotencyKey)
+			record = nil
+		}
+		if record != nil {
+			log.Infof("idempotency key %s already succeeded, skipping the request", idempotencyKey)
+			_, _, err = h.handleResponse(log, info, fileHandler, record.response())
+			if err != nil {
+				return nil, err
+			}
+			if record.truncated && h.config.WriteResponseToMetadata {
+				info.Metadata["UploadHTTP.ResponseBodyTruncated"] = true
+			}
+			info.Metadata["UploadHTTP.ResponseStatusCode"] = record.status
+			info.Metadata["UploadHTTP.Attempts"] = 0
+			info.Metadata["UploadHTTP.URL"] = url
+			info.Metadata["UploadHTTP.IdempotentReplay"] = true
+			return info, nil
 		}
-		req.Header.Set(key, value)
+		headers.Set(h.config.IdempotencyHeader, idempotencyKey)
 	}
 
-	resp, err := h.client.Do(req)
+	source := &contentSource{fileHandler: fileHandler}
+	var resp *http.Response
+	var attempts int
+	if h.config.Type == sendFileTus {
+		resp, attempts, err = h.sendTus(log, info, source, url, headers)
+	} else {
+		resp, attempts, err = h.sendWithRetries(log, info, source, method, url, headers)
+	}
 	if err != nil {
-		log.WithError(err).Errorf("failed to send HTTP request")
-		return nil, fmt.Errorf("failed to send HTTP request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(resp.Body)
+	body, truncated, err := h.handleResponse(log, info, fileHandler, resp)
 	if err != nil {
-		log.WithError(err).Errorf("failed to read response body")
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		log.Errorf("received non-2xx response: %s, body: %s", resp.Status, string(respBody))
-		return nil, fmt.Errorf("received non-2xx response: %s", resp.Status)
+		return nil, err
 	}
-
-	log.Debugf("Response status: %s", resp.Status)
-	log.Debugf("Response body: %s", string(respBody))
-
-	if h.config.PutResponseAsContents {
-		writer, err := fileHandler.Write()
-		if err != nil {
-			log.WithError(err).Errorf("failed to write response to file")
-			return nil, fmt.Errorf("failed to write response to file: %w", err)
-		}
-		_, err = writer.Write(respBody)
+	if idempotencyKey != "" {
+		err = h.storeIdempotencyRecord(idempotencyKey, resp, body, truncated)
 		if err != nil {
-			log.WithError(err).Errorf("failed to write response to file")
-			return nil, fmt.Errorf("failed to write response to file: %w", err)
+			log.WithError(err).Errorf("failed to store idempotency record")
+			return nil, fmt.Errorf("failed to store idempotency record: %w", err)
 		}
+		info.Metadata["UploadHTTP.IdempotentReplay"] = false
 	}
 
 	info.Metadata["UploadHTTP.ResponseStatusCode"] = resp.StatusCode
-	if h.config.WriteResponseToMetadata {
-		info.Metadata["UploadHTTP.ResponseBody"] = string(respBody)
-		info.Metadata["UploadHTTP.ResponseHeaders"] = resp.Header
-	}
+	info.Metadata["UploadHTTP.Attempts"] = attempts
 	info.Metadata["UploadHTTP.URL"] = url
 	return info, nil
 }
@@ -193,12 +436,17 @@ func (h *UploadHTTP) generateMultipart(
 	writer *multipart.Writer,
 	reader io.Reader,
 ) error {
-	fieldName, err := evaluateAndLog(log, info, h.config.MultipartFieldName, "field name")
+	fieldName, err := evaluateAndLog(log, info, h.compiled.multipartFieldName, "field name")
+	if err != nil {
+		return err
+	}
+
+	filename, err := evaluateAndLog(log, info, h.compiled.multipartFilename, "filename")
 	if err != nil {
 		return err
 	}
 
-	filename, err := evaluateAndLog(log, info, h.config.MultipartFilename, "filename")
+	err = h.writeMultipartFields(log, info, writer)
 	if err != nil {
 		return err
 	}
@@ -221,9 +469,10 @@ func (h *UploadHTTP) generateMultipart(
 func evaluateAndLog(
 	log *logrus.Logger,
 	info *definitions.EngineFlowObject,
-	expression, name string,
+	e *expression.Expression,
+	name string,
 ) (string, error) {
-	value, err := info.EvaluateExpression(expression)
+	value, err := e.Evaluate(info.Metadata)
 	if err != nil {
 		log.WithError(err).Errorf("failed to evaluate %s", name)
 		return "", fmt.Errorf("failed to evaluate %s: %w", name, err)
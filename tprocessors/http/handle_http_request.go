@@ -0,0 +1,110 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpregistry"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+)
+
+// HandleHTTPRequest accepts requests like ListenHTTP, but parks them in the registry under a
+// correlation ID, so a HandleHTTPResponse processor of the flow writes their response.
+type HandleHTTPRequest struct {
+	definitions.BaseProcessor
+	config       *handleHTTPRequestConfig
+	listener     *listener
+	stateManager definitions.StateManager
+	registry     *httpregistry.Registry
+}
+
+type handleHTTPRequestConfig struct {
+	listenerConfig `mapstructure:",squash"`
+}
+
+func NewHandleHTTPRequest(stateManager definitions.StateManager, registry *httpregistry.Registry) definitions.TriggerProcessor {
+	return &HandleHTTPRequest{
+		stateManager: stateManager,
+		registry:     registry,
+	}
+}
+
+func (*HandleHTTPRequest) Name() string {
+	return "HandleHTTPRequest"
+}
+
+func (h *HandleHTTPRequest) GetScheduleType() definitions.ScheduleType {
+	return definitions.EventDriven
+}
+
+func (h *HandleHTTPRequest) SetConfig(conf map[string]interface{}) error {
+	h.config = &handleHTTPRequestConfig{}
+	err := h.DecodeMap(conf, h.config)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to decode config")
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	// the previous server has to release the address before the new one binds it
+	if h.listener != nil {
+		err = h.Close()
+		if err != nil {
+			logrus.WithError(err).Warnf("failed to stop the previous HTTP server")
+		}
+	}
+	h.listener, err = newListener(h.config.listenerConfig, h.stateManager)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to start HTTP server")
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
+	return nil
+}
+
+func (h *HandleHTTPRequest) Close() error {
+	if h.listener == nil {
+		return nil
+	}
+	return h.listener.close()
+}
+
+func (h *HandleHTTPRequest) Execute(
+	info *definitions.EngineFlowObject,
+	produceFileHandler func() definitions.ProcessorFileHandler,
+	log *logrus.Logger,
+) ([]*definitions.TriggerProcessorResponse, error) {
+	log.Trace("starting HandleHTTPRequest execution")
+	responses := h.listener.accept("HandleHTTPRequest", produceFileHandler, log, func(request *parkedRequest, metadata map[string]interface{}) string {
+		correlationID := uuid.New().String()
+		h.registry.Park(correlationID, func(resp httpregistry.Response) {
+			request.reply(resp.StatusCode, resp.Headers, resp.Body)
+		})
+		go func() {
+			// requests that time out or whose client disconnects can no longer be answered
+			<-request.done
+			h.registry.Release(correlationID)
+		}()
+		metadata["HandleHTTPRequest.CorrelationID"] = correlationID
+		return correlationID
+	})
+	log.Debugf("completed HandleHTTPRequest execution with %d requests", len(responses))
+	return responses, nil
+}
+
+// HandleSessionUpdate answers the requests whose session finished without a response with 500,
+// instead of leaving their clients waiting until the response timeout.
+func (h *HandleHTTPRequest) HandleSessionUpdate(update definitions.SessionUpdate) {
+	if !update.Finished {
+		return
+	}
+	err := h.registry.Respond(update.TPMark, httpregistry.Response{StatusCode: http.StatusInternalServerError})
+	if errors.Is(err, httpregistry.ErrNotParked) {
+		return
+	}
+	if update.Error != nil {
+		logrus.WithError(update.Error).Errorf("session %s finished with error", update.SessionID)
+	} else {
+		logrus.Warnf("session %s finished without responding to its request", update.SessionID)
+	}
+}
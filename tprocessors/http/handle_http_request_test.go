@@ -0,0 +1,95 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpregistry"
+	"github.com/go-streamline/standard-processors-bundle/processors/handlehttpresponse"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestHandleHTTPRequest(t *testing.T, registry *httpregistry.Registry, conf map[string]interface{}) (*HandleHTTPRequest, string) {
+	h := NewHandleHTTPRequest(&MockStateManager{}, registry).(*HandleHTTPRequest)
+	conf["address"] = "127.0.0.1:0"
+	err := h.SetConfig(conf)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = h.Close() })
+	return h, "http://" + h.listener.addr.String()
+}
+
+func TestHandleHTTPRequest_Response(t *testing.T) {
+	registry := httpregistry.New()
+	h, url := newTestHandleHTTPRequest(t, registry, map[string]interface{}{})
+	p := handlehttpresponse.NewHandleHTTPResponse(registry)
+	err := p.SetConfig(map[string]interface{}{
+		"status_code": "${Status}",
+		"headers":     map[string]interface{}{"Content-Type": "application/json"},
+	})
+	assert.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodPost, url+"/orders", strings.NewReader(`{"id": 1}`))
+	results := send(req)
+	responses, err := h.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"id": 1}`}, contents(t, responses))
+	info := responses[0].EngineFlowObject
+	assert.Equal(t, "/orders", info.Metadata["HandleHTTPRequest.Path"])
+	assert.NotEmpty(t, info.Metadata["HandleHTTPRequest.CorrelationID"])
+
+	info.Metadata["Status"] = "201"
+	_, err = p.Execute(info, &MockEngineFileHandler{writer: bytes.NewBufferString(`{"created": true}`)}, logrus.New())
+	assert.NoError(t, err)
+	res := <-results
+	assert.NoError(t, res.err)
+	assert.Equal(t, http.StatusCreated, res.status)
+	assert.Equal(t, `{"created": true}`, res.body)
+
+	// the request was already answered
+	h.HandleSessionUpdate(definitions.SessionUpdate{Finished: true, TPMark: info.TPMark})
+	_, err = p.Execute(info, &MockEngineFileHandler{writer: new(bytes.Buffer)}, logrus.New())
+	assert.ErrorIs(t, err, httpregistry.ErrNotParked)
+}
+
+func TestHandleHTTPRequest_Session_Error(t *testing.T) {
+	registry := httpregistry.New()
+	h, url := newTestHandleHTTPRequest(t, registry, map[string]interface{}{})
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/orders", nil)
+	results := send(req)
+	responses, err := h.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+
+	h.HandleSessionUpdate(definitions.SessionUpdate{
+		Finished: true,
+		Error:    errors.New("failed"),
+		TPMark:   responses[0].EngineFlowObject.TPMark,
+	})
+	res := <-results
+	assert.Equal(t, http.StatusInternalServerError, res.status)
+	correlationID := responses[0].EngineFlowObject.Metadata["HandleHTTPRequest.CorrelationID"].(string)
+	assert.ErrorIs(t, registry.Respond(correlationID, httpregistry.Response{}), httpregistry.ErrNotParked)
+}
+
+func TestHandleHTTPRequest_Timeout(t *testing.T) {
+	registry := httpregistry.New()
+	h, url := newTestHandleHTTPRequest(t, registry, map[string]interface{}{"response_timeout": "100ms"})
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/orders", nil)
+	results := send(req)
+	responses, err := h.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	res := <-results
+	assert.Equal(t, http.StatusGatewayTimeout, res.status)
+
+	// the request is released once its client stopped waiting, so a late response is an error
+	correlationID := responses[0].EngineFlowObject.Metadata["HandleHTTPRequest.CorrelationID"].(string)
+	assert.Eventually(t, func() bool {
+		return errors.Is(registry.Respond(correlationID, httpregistry.Response{}), httpregistry.ErrNotParked)
+	}, time.Second, 10*time.Millisecond)
+}
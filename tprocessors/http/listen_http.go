@@ -0,0 +1,109 @@
+package http
+
+import (
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"sync"
+)
+
+type ListenHTTP struct {
+	definitions.BaseProcessor
+	config       *listenHTTPConfig
+	listener     *listener
+	stateManager definitions.StateManager
+	mu           sync.Mutex
+	pending      map[string]*parkedRequest // by TPMark, until their session finishes
+}
+
+type listenHTTPConfig struct {
+	listenerConfig `mapstructure:",squash"`
+}
+
+func NewListenHTTP(stateManager definitions.StateManager) definitions.TriggerProcessor {
+	return &ListenHTTP{
+		stateManager: stateManager,
+		pending:      make(map[string]*parkedRequest),
+	}
+}
+
+func (*ListenHTTP) Name() string {
+	return "ListenHTTP"
+}
+
+func (l *ListenHTTP) GetScheduleType() definitions.ScheduleType {
+	return definitions.EventDriven
+}
+
+func (l *ListenHTTP) SetConfig(conf map[string]interface{}) error {
+	l.config = &listenHTTPConfig{}
+	err := l.DecodeMap(conf, l.config)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to decode config")
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	// the previous server has to release the address before the new one binds it
+	if l.listener != nil {
+		err = l.Close()
+		if err != nil {
+			logrus.WithError(err).Warnf("failed to stop the previous HTTP server")
+		}
+	}
+	l.listener, err = newListener(l.config.listenerConfig, l.stateManager)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to start HTTP server")
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
+	return nil
+}
+
+func (l *ListenHTTP) Close() error {
+	if l.listener == nil {
+		return nil
+	}
+	// closing the listener answers the pending requests with 503
+	l.mu.Lock()
+	clear(l.pending)
+	l.mu.Unlock()
+	return l.listener.close()
+}
+
+func (l *ListenHTTP) Execute(
+	info *definitions.EngineFlowObject,
+	produceFileHandler func() definitions.ProcessorFileHandler,
+	log *logrus.Logger,
+) ([]*definitions.TriggerProcessorResponse, error) {
+	log.Trace("starting ListenHTTP execution")
+	responses := l.listener.accept("ListenHTTP", produceFileHandler, log, func(request *parkedRequest, _ map[string]interface{}) string {
+		tpMark := uuid.New().String()
+		l.mu.Lock()
+		l.pending[tpMark] = request
+		l.mu.Unlock()
+		return tpMark
+	})
+	log.Debugf("completed ListenHTTP execution with %d requests", len(responses))
+	return responses, nil
+}
+
+func (l *ListenHTTP) HandleSessionUpdate(update definitions.SessionUpdate) {
+	if !update.Finished {
+		return
+	}
+	l.mu.Lock()
+	request, ok := l.pending[update.TPMark]
+	delete(l.pending, update.TPMark)
+	l.mu.Unlock()
+	if !ok {
+		logrus.Errorf("request not found for session %s", update.SessionID)
+		return
+	}
+	if update.Error != nil {
+		logrus.WithError(update.Error).Errorf("session %s finished with error", update.SessionID)
+		request.reply(http.StatusInternalServerError, nil, nil)
+		return
+	}
+	request.reply(http.StatusOK, nil, nil)
+}
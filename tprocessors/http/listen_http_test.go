@@ -0,0 +1,276 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestListenHTTP(t *testing.T, conf map[string]interface{}) (*ListenHTTP, string) {
+	l := NewListenHTTP(&MockStateManager{}).(*ListenHTTP)
+	conf["address"] = "127.0.0.1:0"
+	err := l.SetConfig(conf)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	return l, "http://" + l.listener.addr.String()
+}
+
+type result struct {
+	status int
+	body   string
+	err    error
+}
+
+func send(req *http.Request) chan result {
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		results <- result{status: resp.StatusCode, body: string(body)}
+	}()
+	return results
+}
+
+func TestListenHTTP_Acknowledge(t *testing.T) {
+	l, url := newTestListenHTTP(t, map[string]interface{}{
+		"allowed_paths": []string{"/hooks/*"},
+		"auth":          map[string]interface{}{"type": "bearer", "token": "secret"},
+	})
+
+	for _, sessionErr := range []error{nil, errors.New("failed")} {
+		req, _ := http.NewRequest(http.MethodPost, url+"/hooks/orders?source=shop", strings.NewReader(`{"id": 1}`))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Event", "created")
+		results := send(req)
+
+		responses, err := l.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+		assert.NoError(t, err)
+		assert.Equal(t, []string{`{"id": 1}`}, contents(t, responses))
+		metadata := responses[0].EngineFlowObject.Metadata
+		assert.Equal(t, http.MethodPost, metadata["ListenHTTP.Method"])
+		assert.Equal(t, "/hooks/orders", metadata["ListenHTTP.Path"])
+		assert.Equal(t, neturl.Values{"source": {"shop"}}, metadata["ListenHTTP.Query"])
+		assert.Equal(t, "created", metadata["ListenHTTP.Headers"].(http.Header).Get("X-Event"))
+		assert.NotEmpty(t, metadata["ListenHTTP.RemoteAddr"])
+
+		// the response is held until the session finishes
+		select {
+		case <-results:
+			t.Fatal("responded before the session finished")
+		default:
+		}
+		l.HandleSessionUpdate(definitions.SessionUpdate{TPMark: responses[0].EngineFlowObject.TPMark})
+		l.HandleSessionUpdate(definitions.SessionUpdate{
+			Finished: true,
+			Error:    sessionErr,
+			TPMark:   responses[0].EngineFlowObject.TPMark,
+		})
+		res := <-results
+		assert.NoError(t, res.err)
+		if sessionErr == nil {
+			assert.Equal(t, http.StatusOK, res.status)
+		} else {
+			assert.Equal(t, http.StatusInternalServerError, res.status)
+		}
+	}
+}
+
+func TestListenHTTP_Rejected(t *testing.T) {
+	_, url := newTestListenHTTP(t, map[string]interface{}{
+		"allowed_paths": []string{"/hooks/*"},
+		"max_body_size": 4,
+		"auth":          map[string]interface{}{"type": "basic", "username": "user", "password": "pass"},
+	})
+
+	req, _ := http.NewRequest(http.MethodPost, url+"/admin", nil)
+	req.SetBasicAuth("user", "pass")
+	res := <-send(req)
+	assert.Equal(t, http.StatusNotFound, res.status)
+
+	req, _ = http.NewRequest(http.MethodPost, url+"/hooks/orders", nil)
+	req.SetBasicAuth("user", "wrong")
+	res = <-send(req)
+	assert.Equal(t, http.StatusUnauthorized, res.status)
+
+	req, _ = http.NewRequest(http.MethodPost, url+"/hooks/orders", strings.NewReader("too large"))
+	req.SetBasicAuth("user", "pass")
+	res = <-send(req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, res.status)
+}
+
+func TestListenHTTP_Response_Timeout(t *testing.T) {
+	l, url := newTestListenHTTP(t, map[string]interface{}{
+		"response_timeout": "100ms",
+	})
+
+	req, _ := http.NewRequest(http.MethodGet, url+"/", nil)
+	results := send(req)
+	responses, err := l.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Len(t, responses, 1)
+	res := <-results
+	assert.Equal(t, http.StatusGatewayTimeout, res.status)
+
+	// a late session update does not block
+	l.HandleSessionUpdate(definitions.SessionUpdate{Finished: true, TPMark: responses[0].EngineFlowObject.TPMark})
+
+	err = l.Close()
+	assert.NoError(t, err)
+	responses, err = l.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Empty(t, responses)
+}
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestListenHTTP_Signature(t *testing.T) {
+	stateManager := &MockStateManager{}
+	l := NewListenHTTP(stateManager).(*ListenHTTP)
+	err := l.SetConfig(map[string]interface{}{
+		"address": "127.0.0.1:0",
+		"signature": map[string]interface{}{
+			"secret":           "webhook-secret",
+			"signature_header": "X-Hub-Signature-256",
+			"signature_prefix": "sha256=",
+			"nonce_header":     "X-GitHub-Delivery",
+		},
+	})
+	assert.NoError(t, err)
+	defer l.Close()
+	url := "http://" + l.listener.addr.String()
+
+	body := `{"action": "opened"}`
+	newRequest := func(signature, delivery string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, url+"/", strings.NewReader(body))
+		req.Header.Set("X-Hub-Signature-256", signature)
+		req.Header.Set("X-GitHub-Delivery", delivery)
+		return req
+	}
+
+	results := send(newRequest("sha256="+sign("webhook-secret", body), "delivery-1"))
+	responses, err := l.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{body}, contents(t, responses))
+	l.HandleSessionUpdate(definitions.SessionUpdate{Finished: true, TPMark: responses[0].EngineFlowObject.TPMark})
+	assert.Equal(t, http.StatusOK, (<-results).status)
+	assert.Contains(t, stateManager.state, "nonce:delivery-1")
+
+	// rejected before reaching Execute, which would otherwise block the response
+	for name, req := range map[string]*http.Request{
+		"replay":         newRequest("sha256="+sign("webhook-secret", body), "delivery-1"),
+		"wrong secret":   newRequest("sha256="+sign("other-secret", body), "delivery-2"),
+		"missing prefix": newRequest(sign("webhook-secret", body), "delivery-3"),
+		"missing nonce":  newRequest("sha256="+sign("webhook-secret", body), ""),
+	} {
+		assert.Equal(t, http.StatusUnauthorized, (<-send(req)).status, name)
+	}
+}
+
+func TestListenHTTP_Signature_Retry_After_Failure(t *testing.T) {
+	stateManager := &MockStateManager{}
+	l := NewListenHTTP(stateManager).(*ListenHTTP)
+	err := l.SetConfig(map[string]interface{}{
+		"address": "127.0.0.1:0",
+		"signature": map[string]interface{}{
+			"secret":       "webhook-secret",
+			"nonce_header": "X-GitHub-Delivery",
+		},
+	})
+	assert.NoError(t, err)
+	defer l.Close()
+	url := "http://" + l.listener.addr.String()
+
+	body := `{"action": "opened"}`
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, url+"/", strings.NewReader(body))
+		req.Header.Set("X-Signature", sign("webhook-secret", body))
+		req.Header.Set("X-GitHub-Delivery", "delivery-1")
+		return req
+	}
+
+	results := send(newRequest())
+	responses, err := l.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	// the provider retries while the first attempt still waits for its reply
+	assert.Equal(t, http.StatusConflict, (<-send(newRequest())).status)
+	l.HandleSessionUpdate(definitions.SessionUpdate{Finished: true, Error: errors.New("failed"), TPMark: responses[0].EngineFlowObject.TPMark})
+	assert.Equal(t, http.StatusInternalServerError, (<-results).status)
+	assert.NotContains(t, stateManager.state, "nonce:delivery-1")
+
+	// the failed delivery is accepted again
+	results = send(newRequest())
+	responses, err = l.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{body}, contents(t, responses))
+	l.HandleSessionUpdate(definitions.SessionUpdate{Finished: true, TPMark: responses[0].EngineFlowObject.TPMark})
+	assert.Equal(t, http.StatusOK, (<-results).status)
+	assert.Contains(t, stateManager.state, "nonce:delivery-1")
+
+	assert.Equal(t, http.StatusUnauthorized, (<-send(newRequest())).status)
+}
+
+func TestListenHTTP_Signature_Timestamped(t *testing.T) {
+	now := time.Unix(1760000000, 0)
+	l := NewListenHTTP(&MockStateManager{}).(*ListenHTTP)
+	err := l.SetConfig(map[string]interface{}{
+		"address": "127.0.0.1:0",
+		"signature": map[string]interface{}{
+			"scheme":           "stripe",
+			"secret":           "whsec",
+			"signature_header": "Stripe-Signature",
+			"tolerance":        "1m",
+		},
+	})
+	assert.NoError(t, err)
+	defer l.Close()
+	l.listener.verifier.now = func() time.Time { return now }
+	url := "http://" + l.listener.addr.String()
+
+	body := `{"type": "charge.succeeded"}`
+	newRequest := func(timestamp int64, signatures ...string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, url+"/", strings.NewReader(body))
+		header := fmt.Sprintf("t=%d", timestamp)
+		for _, signature := range signatures {
+			header += ",v1=" + signature
+		}
+		req.Header.Set("Stripe-Signature", header)
+		return req
+	}
+
+	timestamp := now.Add(-30 * time.Second).Unix()
+	valid := sign("whsec", fmt.Sprintf("%d.%s", timestamp, body))
+	results := send(newRequest(timestamp, "deadbeef", valid))
+	responses, err := l.Execute(&definitions.EngineFlowObject{}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	l.HandleSessionUpdate(definitions.SessionUpdate{Finished: true, TPMark: responses[0].EngineFlowObject.TPMark})
+	assert.Equal(t, http.StatusOK, (<-results).status)
+
+	stale := now.Add(-2 * time.Minute).Unix()
+	for name, req := range map[string]*http.Request{
+		"replay":          newRequest(timestamp, valid),
+		"stale":           newRequest(stale, sign("whsec", fmt.Sprintf("%d.%s", stale, body))),
+		"other timestamp": newRequest(timestamp+1, valid),
+	} {
+		assert.Equal(t, http.StatusUnauthorized, (<-send(req)).status, name)
+	}
+}
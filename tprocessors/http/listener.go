@@ -0,0 +1,438 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultListenAddress   = ":8080"
+	defaultMaxBodySize     = 10 << 20
+	defaultResponseTimeout = 30 * time.Second
+	readHeaderTimeout      = 10 * time.Second
+	shutdownTimeout        = 5 * time.Second
+)
+
+type serverAuthType string
+
+const (
+	serverAuthNone   serverAuthType = ""
+	serverAuthBasic  serverAuthType = "basic"
+	serverAuthBearer serverAuthType = "bearer"
+)
+
+// listenerConfig holds the settings of the embedded server, shared by the triggers that accept requests.
+type listenerConfig struct {
+	Address         string           `mapstructure:"address,omitempty"`
+	AllowedPaths    []string         `mapstructure:"allowed_paths,omitempty"`    // path.Match patterns, every path is allowed if not set
+	MaxBodySize     int64            `mapstructure:"max_body_size,omitempty"`    // in bytes
+	ResponseTimeout string           `mapstructure:"response_timeout,omitempty"` // time a request waits for its response
+	Auth            serverAuthConfig `mapstructure:"auth,omitempty"`
+	TLS             serverTLSConfig  `mapstructure:"tls,omitempty"`
+	Signature       signatureConfig  `mapstructure:"signature,omitempty"`
+}
+
+type serverAuthConfig struct {
+	Type     serverAuthType `mapstructure:"type"`
+	Username string         `mapstructure:"username,omitempty"`
+	Password string         `mapstructure:"password,omitempty"`
+	Token    string         `mapstructure:"token,omitempty"`
+}
+
+type serverTLSConfig struct {
+	CertFile     string `mapstructure:"cert_file,omitempty"`
+	KeyFile      string `mapstructure:"key_file,omitempty"`
+	ClientCAFile string `mapstructure:"client_ca_file,omitempty"` // clients must present a certificate signed by it if set
+	MinVersion   string `mapstructure:"min_version,omitempty"`    // 1.0, 1.1, 1.2 or 1.3
+}
+
+// parkedRequest is an accepted request whose client waits for a response.
+type parkedRequest struct {
+	method     string
+	path       string
+	query      neturl.Values
+	headers    http.Header
+	remoteAddr string
+	body       []byte
+	respond    chan parkedResponse
+	done       chan struct{} // closed once the client stopped waiting
+}
+
+type parkedResponse struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+// reply sends the response to the client of r. Replies after the first one, or after the client
+// stopped waiting, are dropped.
+func (r *parkedRequest) reply(status int, headers http.Header, body []byte) {
+	select {
+	case r.respond <- parkedResponse{status: status, headers: headers, body: body}:
+	default:
+	}
+}
+
+// listener runs the embedded server. Every accepted request is handed over through next and
+// held until it gets a reply or its response timeout passes.
+type listener struct {
+	config          listenerConfig
+	responseTimeout time.Duration
+	verifier        *signatureVerifier
+	server          *http.Server
+	addr            net.Addr
+	requests        chan *parkedRequest
+	closed          chan struct{}
+	closeOnce       sync.Once
+}
+
+func (c *listenerConfig) validate() error {
+	if c.Address == "" {
+		c.Address = defaultListenAddress
+	}
+	if c.MaxBodySize < 0 {
+		return fmt.Errorf("max_body_size must not be negative")
+	}
+	if c.MaxBodySize == 0 {
+		c.MaxBodySize = defaultMaxBodySize
+	}
+	for _, pattern := range c.AllowedPaths {
+		if _, err := path.Match(pattern, "/"); err != nil {
+			return fmt.Errorf("invalid allowed_paths pattern %s: %w", pattern, err)
+		}
+	}
+	switch c.Auth.Type {
+	case serverAuthNone:
+	case serverAuthBasic:
+		if c.Auth.Username == "" || c.Auth.Password == "" {
+			return fmt.Errorf("username and password are required for basic auth")
+		}
+	case serverAuthBearer:
+		if c.Auth.Token == "" {
+			return fmt.Errorf("token is required for bearer auth")
+		}
+	default:
+		return fmt.Errorf("unsupported auth type %s", c.Auth.Type)
+	}
+	return nil
+}
+
+func (c *serverTLSConfig) build() (*tls.Config, error) {
+	if *c == (serverTLSConfig{}) {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("cert_file and key_file are required for TLS")
+	}
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	tlsConf := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if c.MinVersion != "" {
+		tlsConf.MinVersion, err = httpclient.ParseTLSVersion(c.MinVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.ClientCAFile != "" {
+		caPEM, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in client_ca_file %s", c.ClientCAFile)
+		}
+		tlsConf.ClientCAs = pool
+		tlsConf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConf, nil
+}
+
+// newListener validates conf and starts serving on its address. The state manager keeps the nonces
+// of signed requests.
+func newListener(conf listenerConfig, stateManager definitions.StateManager) (*listener, error) {
+	err := conf.validate()
+	if err != nil {
+		return nil, err
+	}
+	responseTimeout, err := httpclient.ParseDuration(conf.ResponseTimeout, defaultResponseTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("response_timeout: %w", err)
+	}
+	tlsConf, err := conf.TLS.build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls config: %w", err)
+	}
+	verifier, err := newSignatureVerifier(conf.Signature, stateManager)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature config: %w", err)
+	}
+
+	l := &listener{
+		config:          conf,
+		responseTimeout: responseTimeout,
+		verifier:        verifier,
+		requests:        make(chan *parkedRequest),
+		closed:          make(chan struct{}),
+	}
+	l.server = &http.Server{
+		Handler:           l,
+		TLSConfig:         tlsConf,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+	ln, err := net.Listen("tcp", conf.Address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", conf.Address, err)
+	}
+	l.addr = ln.Addr()
+	if tlsConf != nil {
+		ln = tls.NewListener(ln, tlsConf)
+	}
+	go func() {
+		err := l.server.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logrus.WithError(err).Errorf("HTTP server on %s stopped", l.addr)
+		}
+	}()
+	logrus.Infof("listening for HTTP requests on %s", l.addr)
+	return l, nil
+}
+
+// next blocks until a request is accepted, and returns it along with every other request already
+// waiting. It returns false once the listener is closed.
+func (l *listener) next() ([]*parkedRequest, bool) {
+	var requests []*parkedRequest
+	select {
+	case request := <-l.requests:
+		requests = append(requests, request)
+	case <-l.closed:
+		return nil, false
+	}
+	for {
+		select {
+		case request := <-l.requests:
+			requests = append(requests, request)
+		default:
+			return requests, true
+		}
+	}
+}
+
+// accept waits for the next requests and turns each into a response of the trigger, whose metadata
+// keys start with prefix. park is called for every request whose body was written, fills in any
+// metadata of its own and returns the TPMark of the request. Requests whose body cannot be written
+// are answered with 500 right away. It returns nil once the listener is closed.
+func (l *listener) accept(
+	prefix string,
+	produceFileHandler func() definitions.ProcessorFileHandler,
+	log *logrus.Logger,
+	park func(request *parkedRequest, metadata map[string]interface{}) string,
+) []*definitions.TriggerProcessorResponse {
+	requests, ok := l.next()
+	if !ok {
+		log.Debug("HTTP server is closed")
+		return nil
+	}
+
+	var responses []*definitions.TriggerProcessorResponse
+	for _, request := range requests {
+		log.Debugf("request received: %s %s from %s", request.method, request.path, request.remoteAddr)
+		fileHandler := produceFileHandler()
+		writer, err := fileHandler.Write()
+		if err != nil {
+			log.WithError(err).Errorf("failed to get writer for file handler")
+			request.reply(http.StatusInternalServerError, nil, nil)
+			continue
+		}
+		_, err = writer.Write(request.body)
+		if err != nil {
+			log.WithError(err).Errorf("failed to write request body to file handler")
+			request.reply(http.StatusInternalServerError, nil, nil)
+			continue
+		}
+
+		metadata := map[string]interface{}{
+			prefix + ".Method":     request.method,
+			prefix + ".Path":       request.path,
+			prefix + ".Query":      request.query,
+			prefix + ".Headers":    request.headers,
+			prefix + ".RemoteAddr": request.remoteAddr,
+		}
+		tpMark := park(request, metadata)
+		responses = append(responses, &definitions.TriggerProcessorResponse{
+			EngineFlowObject: &definitions.EngineFlowObject{
+				Metadata: metadata,
+				TPMark:   tpMark,
+			},
+			FileHandler: fileHandler,
+		})
+	}
+	return responses
+}
+
+func (l *listener) close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		err = l.server.Shutdown(ctx)
+	})
+	return err
+}
+
+func (l *listener) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !l.pathAllowed(r.URL.Path) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+	if !l.authorized(r) {
+		if l.config.Auth.Type == serverAuthBasic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="restricted"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, l.config.MaxBodySize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+			return
+		}
+		logrus.WithError(err).Warnf("failed to read the body of %s %s", r.Method, r.URL.Path)
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	// the nonce of a signed request is only recorded once it is answered with 2xx, every other
+	// outcome releases it so the provider can deliver the request again
+	var nonce string
+	var delivered bool
+	if l.verifier != nil {
+		nonce, err = l.verifier.verify(r, body)
+		if errors.Is(err, errNonceInFlight) {
+			logrus.WithError(err).Warnf("rejected %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusConflict), http.StatusConflict)
+			return
+		}
+		var verifierErr *verifierError
+		if errors.As(err, &verifierErr) {
+			logrus.WithError(err).Errorf("failed to verify the signature of %s %s", r.Method, r.URL.Path)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Warnf("rejected %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		defer func() {
+			if !delivered {
+				l.verifier.releaseNonce(nonce)
+			}
+		}()
+	}
+
+	request := &parkedRequest{
+		method:     r.Method,
+		path:       r.URL.Path,
+		query:      r.URL.Query(),
+		headers:    r.Header.Clone(),
+		remoteAddr: r.RemoteAddr,
+		body:       body,
+		respond:    make(chan parkedResponse, 1),
+		done:       make(chan struct{}),
+	}
+	defer close(request.done)
+	timeout := time.NewTimer(l.responseTimeout)
+	defer timeout.Stop()
+	select {
+	case l.requests <- request:
+	case <-timeout.C:
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	case <-l.closed:
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	case <-r.Context().Done():
+		return
+	}
+
+	select {
+	case response := <-request.respond:
+		if l.verifier != nil && response.status >= 200 && response.status < 300 {
+			err = l.verifier.commitNonce(nonce)
+			if err != nil {
+				logrus.WithError(err).Errorf("failed to record the nonce of %s %s", r.Method, r.URL.Path)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			delivered = true
+		}
+		for key, values := range response.headers {
+			w.Header()[key] = values
+		}
+		w.WriteHeader(response.status)
+		_, err = w.Write(response.body)
+		if err != nil {
+			logrus.WithError(err).Warnf("failed to write the response of %s %s", r.Method, r.URL.Path)
+		}
+	case <-timeout.C:
+		logrus.Warnf("%s %s got no response within %s", r.Method, r.URL.Path, l.responseTimeout)
+		http.Error(w, http.StatusText(http.StatusGatewayTimeout), http.StatusGatewayTimeout)
+	case <-l.closed:
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
+
+func (l *listener) pathAllowed(requestPath string) bool {
+	if len(l.config.AllowedPaths) == 0 {
+		return true
+	}
+	for _, pattern := range l.config.AllowedPaths {
+		if matched, _ := path.Match(pattern, requestPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *listener) authorized(r *http.Request) bool {
+	switch l.config.Auth.Type {
+	case serverAuthBasic:
+		username, password, ok := r.BasicAuth()
+		return ok && secureEqual(username, l.config.Auth.Username) && secureEqual(password, l.config.Auth.Password)
+	case serverAuthBearer:
+		scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+		return ok && strings.EqualFold(scheme, "Bearer") && secureEqual(token, l.config.Auth.Token)
+	}
+	return true
+}
+
+// secureEqual compares credentials in constant time.
+func secureEqual(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
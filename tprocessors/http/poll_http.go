@@ -0,0 +1,538 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/interfaces/utils"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/go-streamline/standard-processors-bundle/internal/jsonpath"
+	"github.com/go-streamline/standard-processors-bundle/internal/statevalue"
+	"github.com/sirupsen/logrus"
+	"io"
+	"maps"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxPages    = 100
+	defaultCursorParam = "cursor"
+)
+
+type paginationType string
+
+const (
+	paginationNone   paginationType = ""
+	paginationLink   paginationType = "link"
+	paginationCursor paginationType = "cursor"
+)
+
+type PollHTTP struct {
+	definitions.BaseProcessor
+	config       *pollHTTPConfig
+	compiled     *compiledPollConfig
+	auth         *httpclient.Authenticator
+	transport    *http.Transport
+	client       utils.HTTPClient
+	stateManager definitions.StateManager
+	exprOptions  []expr.Option
+}
+
+type pollHTTPConfig struct {
+	URL             string                     `mapstructure:"url"`
+	Method          string                     `mapstructure:"method,omitempty"`
+	ExtraHeaders    map[string]string          `mapstructure:"extra_headers,omitempty"`
+	Pagination      paginationConfig           `mapstructure:"pagination,omitempty"`
+	MaxResponseBody int64                      `mapstructure:"max_response_body,omitempty"` // in bytes, unlimited if not set
+	Auth            httpclient.AuthConfig      `mapstructure:"auth,omitempty"`
+	TLS             httpclient.TLSConfig       `mapstructure:"tls,omitempty"`
+	Transport       httpclient.TransportConfig `mapstructure:"transport,omitempty"`
+}
+
+type paginationConfig struct {
+	Type        paginationType `mapstructure:"type"`
+	NextCursor  string         `mapstructure:"next_cursor,omitempty"`  // JSONPath or expr over the page
+	CursorParam string         `mapstructure:"cursor_param,omitempty"` // query parameter the cursor is sent in
+	MaxPages    int            `mapstructure:"max_pages,omitempty"`
+}
+
+// compiledPollConfig holds the expressions of pollHTTPConfig, compiled once in SetConfig.
+type compiledPollConfig struct {
+	url        *expression.Expression
+	method     *expression.Expression
+	headers    []expression.Header
+	cursorPath *jsonpath.Path
+	cursorExpr *vm.Program
+}
+
+func NewPollHTTP(stateManager definitions.StateManager, exprOptions ...expr.Option) definitions.TriggerProcessor {
+	return &PollHTTP{
+		stateManager: stateManager,
+		exprOptions:  exprOptions,
+	}
+}
+
+func (*PollHTTP) Name() string {
+	return "PollHTTP"
+}
+
+func (p *PollHTTP) GetScheduleType() definitions.ScheduleType {
+	return definitions.CronDriven
+}
+
+func (*PollHTTP) HandleSessionUpdate(update definitions.SessionUpdate) {
+
+}
+
+func (p *PollHTTP) SetConfig(conf map[string]interface{}) error {
+	p.config = &pollHTTPConfig{}
+	err := p.DecodeMap(conf, p.config)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to decode config")
+		return fmt.Errorf("failed to decode config: %w", err)
+	}
+	if p.config.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if p.config.Method == "" {
+		p.config.Method = http.MethodGet
+	}
+	switch p.config.Pagination.Type {
+	case paginationNone, paginationLink:
+	case paginationCursor:
+		if p.config.Pagination.NextCursor == "" {
+			return fmt.Errorf("pagination next_cursor is required for cursor pagination")
+		}
+		if p.config.Pagination.CursorParam == "" {
+			p.config.Pagination.CursorParam = defaultCursorParam
+		}
+	default:
+		return fmt.Errorf("unsupported pagination type %s", p.config.Pagination.Type)
+	}
+	if p.config.Pagination.MaxPages <= 0 {
+		p.config.Pagination.MaxPages = defaultMaxPages
+	}
+
+	p.compiled, err = p.compileConfig()
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to compile config expressions")
+		return fmt.Errorf("failed to compile config expressions: %w", err)
+	}
+
+	client, transport, ctx, err := httpclient.Configure(p.client, p.transport, p.config.Transport, p.config.TLS)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to create HTTP client")
+		return fmt.Errorf("failed to create HTTP client: %w", err)
+	}
+	p.client, p.transport = client, transport
+
+	p.auth, err = httpclient.NewAuthenticator(ctx, p.config.Auth, p.exprOptions...)
+	if err != nil {
+		logrus.WithError(err).Errorf("invalid auth config")
+		return fmt.Errorf("invalid auth config: %w", err)
+	}
+	return nil
+}
+
+func (p *PollHTTP) compileConfig() (*compiledPollConfig, error) {
+	var err error
+	compiled := &compiledPollConfig{}
+	compiled.url, err = expression.Compile(p.config.URL, p.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	compiled.method, err = expression.Compile(p.config.Method, p.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("method: %w", err)
+	}
+	compiled.headers, err = expression.CompileHeaders(p.config.ExtraHeaders, p.exprOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("extra_headers %w", err)
+	}
+	if p.config.Pagination.Type == paginationCursor {
+		if jsonpath.IsPath(p.config.Pagination.NextCursor) {
+			compiled.cursorPath, err = jsonpath.Compile(p.config.Pagination.NextCursor)
+		} else {
+			compiled.cursorExpr, err = expr.Compile(p.config.Pagination.NextCursor, p.exprOptions...)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pagination next_cursor: %w", err)
+		}
+	}
+	return compiled, nil
+}
+
+func (p *PollHTTP) Close() error {
+	if p.transport != nil {
+		p.transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (p *PollHTTP) Execute(
+	info *definitions.EngineFlowObject,
+	produceFileHandler func() definitions.ProcessorFileHandler,
+	log *logrus.Logger,
+) ([]*definitions.TriggerProcessorResponse, error) {
+	log.Trace("handling PollHTTP")
+
+	url, err := p.compiled.url.Evaluate(info.Metadata)
+	if err != nil {
+		log.WithError(err).Errorf("failed to evaluate url")
+		return nil, fmt.Errorf("failed to evaluate url: %w", err)
+	}
+	method, err := p.compiled.method.Evaluate(info.Metadata)
+	if err != nil {
+		log.WithError(err).Errorf("failed to evaluate method")
+		return nil, fmt.Errorf("failed to evaluate method: %w", err)
+	}
+	headers := make(http.Header)
+	for _, header := range p.compiled.headers {
+		key, value, err := header.Evaluate(info.Metadata)
+		if err != nil {
+			log.WithError(err).Errorf("failed to evaluate header")
+			return nil, err
+		}
+		headers.Set(key, value)
+	}
+
+	state, err := p.stateManager.GetState(definitions.StateTypeLocal)
+	if err != nil {
+		log.WithError(err).Errorf("failed to get state")
+		return nil, fmt.Errorf("failed to get state: %w", err)
+	}
+	if state == nil {
+		state = make(map[string]any)
+	}
+	validators, _ := state[url].(map[string]any)
+	etag, _ := validators["etag"].(string)
+	lastModified, _ := validators["last_modified"].(string)
+
+	pageURL := url
+	firstPage := 1
+	if next, ok := validators["next_page"].(string); ok && next != "" {
+		// the previous poll stopped at max_pages, so this one carries on from the page it stopped at
+		pageURL = next
+		if number, ok := statevalue.Int64(validators["next_page_number"]); ok && number > 1 {
+			firstPage = int(number)
+		}
+		log.Debugf("resuming from page %d", firstPage)
+	}
+
+	var responses []*definitions.TriggerProcessorResponse
+	page := firstPage
+	for ; pageURL != ""; page++ {
+		if page-firstPage >= p.config.Pagination.MaxPages {
+			log.Warnf("stopped after %d pages, the next poll resumes from page %d", p.config.Pagination.MaxPages, page)
+			break
+		}
+		pageHeaders := headers.Clone()
+		if page == 1 {
+			// only the first page is conditional, the pages after it follow from its contents
+			if etag != "" {
+				pageHeaders.Set("If-None-Match", etag)
+			}
+			if lastModified != "" {
+				pageHeaders.Set("If-Modified-Since", lastModified)
+			}
+		}
+
+		resp, body, err := p.fetchPage(log, info, method, pageURL, pageHeaders)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusNotModified {
+			log.Debugf("%s was not modified since the last poll", p.auth.RedactURL(resp.Request.URL))
+			return nil, nil
+		}
+		if page == 1 {
+			etag = resp.Header.Get("ETag")
+			lastModified = resp.Header.Get("Last-Modified")
+		}
+
+		fileHandler := produceFileHandler()
+		writer, err := fileHandler.Write()
+		if err != nil {
+			log.WithError(err).Errorf("failed to write response to file")
+			return nil, fmt.Errorf("failed to write response to file: %w", err)
+		}
+		_, err = writer.Write(body)
+		if err != nil {
+			log.WithError(err).Errorf("failed to write response to file")
+			return nil, fmt.Errorf("failed to write response to file: %w", err)
+		}
+		metadata := maps.Clone(info.Metadata)
+		if metadata == nil {
+			metadata = make(map[string]interface{})
+		}
+		metadata["PollHTTP.StatusCode"] = resp.StatusCode
+		metadata["PollHTTP.Headers"] = resp.Header
+		metadata["PollHTTP.ContentType"] = resp.Header.Get("Content-Type")
+		metadata["PollHTTP.URL"] = p.auth.RedactURL(resp.Request.URL)
+		metadata["PollHTTP.Page"] = page
+		responses = append(responses, &definitions.TriggerProcessorResponse{
+			EngineFlowObject: &definitions.EngineFlowObject{Metadata: metadata},
+			FileHandler:      fileHandler,
+		})
+
+		pageURL, err = p.nextPage(resp, body)
+		if err != nil {
+			log.WithError(err).Errorf("failed to get the next page")
+			return nil, fmt.Errorf("failed to get the next page: %w", err)
+		}
+	}
+
+	// the state is only stored once every page of the poll was fetched, so a failed poll is repeated.
+	// The validators of the first page only make the next poll conditional once the last page was
+	// reached, until then the next poll resumes from the page this one stopped at.
+	entry := make(map[string]any)
+	if etag != "" || lastModified != "" {
+		entry["etag"] = etag
+		entry["last_modified"] = lastModified
+	}
+	if pageURL != "" {
+		entry["next_page"] = pageURL
+		entry["next_page_number"] = page
+	}
+	if len(entry) > 0 {
+		state[url] = entry
+	} else {
+		delete(state, url)
+	}
+	err = p.stateManager.SetState(definitions.StateTypeLocal, state)
+	if err != nil {
+		log.WithError(err).Errorf("failed to set state")
+		return nil, fmt.Errorf("failed to set state: %w", err)
+	}
+
+	log.Debugf("completed PollHTTP execution with %d pages", len(responses))
+	return responses, nil
+}
+
+// fetchPage sends a request and reads its response, which is either 304 or a successful one.
+func (p *PollHTTP) fetchPage(
+	log *logrus.Logger,
+	info *definitions.EngineFlowObject,
+	method, url string,
+	headers http.Header,
+) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(strings.ToUpper(method), url, nil)
+	if err != nil {
+		log.WithError(err).Errorf("failed to create HTTP request")
+		return nil, nil, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	req.Header = headers
+	err = p.auth.Apply(req, info)
+	if err != nil {
+		log.WithError(err).Errorf("failed to authenticate request")
+		return nil, nil, fmt.Errorf("failed to authenticate request: %w", err)
+	}
+	log.Debugf("sending %s %s with headers %v", req.Method, p.auth.RedactURL(req.URL), p.auth.RedactHeaders(req.Header))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = p.auth.RedactURL(req.URL)
+		}
+		log.WithError(err).Errorf("failed to send HTTP request")
+		return nil, nil, fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.Request == nil {
+		resp.Request = req
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		return resp, nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		log.Errorf("received non-2xx response: %s, body: %s", resp.Status, httpclient.ErrorBody(resp.Body))
+		return nil, nil, fmt.Errorf("received non-2xx response: %s", resp.Status)
+	}
+
+	var page bytes.Buffer
+	read, err := io.Copy(&page, httpclient.LimitBody(resp.Body, p.config.MaxResponseBody))
+	if err != nil {
+		log.WithError(err).Errorf("failed to read response body")
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	err = httpclient.CheckBodySize(read, p.config.MaxResponseBody)
+	if err != nil {
+		log.WithError(err).Errorf("response body is too large")
+		return nil, nil, err
+	}
+	return resp, page.Bytes(), nil
+}
+
+// nextPage returns the URL of the page after resp, or an empty string if it was the last one.
+func (p *PollHTTP) nextPage(resp *http.Response, body []byte) (string, error) {
+	var next *neturl.URL
+	switch p.config.Pagination.Type {
+	case paginationLink:
+		link := nextLink(resp.Header.Values("Link"))
+		if link == "" {
+			return "", nil
+		}
+		var err error
+		next, err = resp.Request.URL.Parse(link)
+		if err != nil {
+			return "", fmt.Errorf("invalid next link %q: %w", link, err)
+		}
+	case paginationCursor:
+		cursor, err := p.nextCursor(resp, body)
+		if err != nil || cursor == "" {
+			return "", err
+		}
+		next = new(neturl.URL)
+		*next = *resp.Request.URL
+		query := next.Query()
+		query.Set(p.config.Pagination.CursorParam, cursor)
+		next.RawQuery = query.Encode()
+	default:
+		return "", nil
+	}
+	// an API that hands out the page it was asked for would otherwise be fetched until max_pages
+	if next.String() == resp.Request.URL.String() {
+		return "", nil
+	}
+	return next.String(), nil
+}
+
+// nextCursor evaluates next_cursor over the page. The expr sees the parsed body as `body`, the
+// headers as `headers` and the status code as `status`. A missing, null or empty cursor ends the pagination.
+func (p *PollHTTP) nextCursor(resp *http.Response, body []byte) (string, error) {
+	var cursor any
+	if p.compiled.cursorPath != nil {
+		// numbers are kept as they were written, so large numeric cursors are sent back unchanged
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var parsed any
+		err := decoder.Decode(&parsed)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse response body as JSON: %w", err)
+		}
+		cursor, _ = p.compiled.cursorPath.Get(parsed)
+	} else {
+		// the expr gets plain numbers, so it can compute the cursor from them
+		var parsed any
+		err := json.Unmarshal(body, &parsed)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse response body as JSON: %w", err)
+		}
+		headers := make(map[string]string, len(resp.Header))
+		for key := range resp.Header {
+			headers[key] = resp.Header.Get(key)
+		}
+		cursor, err = expr.Run(p.compiled.cursorExpr, map[string]any{
+			"body":    parsed,
+			"headers": headers,
+			"status":  resp.StatusCode,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to evaluate next_cursor: %w", err)
+		}
+	}
+	switch cursor := cursor.(type) {
+	case nil:
+		return "", nil
+	case float64:
+		return strconv.FormatFloat(cursor, 'f', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(cursor), 'f', -1, 32), nil
+	}
+	return fmt.Sprintf("%v", cursor), nil
+}
+
+// nextLink returns the target of the rel="next" link of the Link headers (RFC 8288). The targets
+// are enclosed in angle brackets and parameter values may be quoted, so both can contain commas and
+// semicolons.
+func nextLink(headers []string) string {
+	for _, header := range headers {
+		for rest := header; ; {
+			rest = strings.TrimLeft(rest, " \t,")
+			if !strings.HasPrefix(rest, "<") {
+				break
+			}
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				break
+			}
+			target := rest[1:end]
+			var params map[string]string
+			params, rest = linkParams(rest[end+1:])
+			for _, rel := range strings.Fields(params["rel"]) {
+				if strings.EqualFold(rel, "next") {
+					return target
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// linkParams parses the ;-separated parameters of a link up to the comma that ends it, and returns
+// them by lower-cased name along with the rest of the header.
+func linkParams(s string) (map[string]string, string) {
+	params := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if !strings.HasPrefix(s, ";") {
+			if i := strings.IndexByte(s, ','); i >= 0 && !strings.HasPrefix(s, ",") {
+				// skip what cannot be parsed up to the next link
+				s = s[i:]
+			}
+			return params, s
+		}
+		s = strings.TrimLeft(s[1:], " \t")
+		nameEnd := strings.IndexAny(s, "=;, \t")
+		if nameEnd < 0 {
+			nameEnd = len(s)
+		}
+		name := strings.ToLower(s[:nameEnd])
+		s = strings.TrimLeft(s[nameEnd:], " \t")
+		var value string
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t")
+			value, s = linkParamValue(s)
+		}
+		// only the first occurrence of a parameter counts
+		if _, ok := params[name]; !ok && name != "" {
+			params[name] = value
+		}
+	}
+}
+
+// linkParamValue reads a token or a quoted string with backslash escapes from the start of s.
+func linkParamValue(s string) (string, string) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexAny(s, ";, \t")
+		if end < 0 {
+			end = len(s)
+		}
+		return s[:end], s[end:]
+	}
+	var value strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value.WriteByte(s[i])
+			}
+		case '"':
+			return value.String(), s[i+1:]
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return value.String(), ""
+}
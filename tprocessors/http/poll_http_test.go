@@ -0,0 +1,348 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// MockEngineFileHandler is a mock implementation of EngineFileHandler for testing
+type MockEngineFileHandler struct {
+	writer *bytes.Buffer
+}
+
+func (m *MockEngineFileHandler) Read() (io.Reader, error) {
+	return bytes.NewReader(m.writer.Bytes()), nil
+}
+
+func (m *MockEngineFileHandler) Write() (io.Writer, error) {
+	return m.writer, nil
+}
+
+func (m *MockEngineFileHandler) Close() {}
+
+func produceFileHandler() definitions.ProcessorFileHandler {
+	return &MockEngineFileHandler{writer: new(bytes.Buffer)}
+}
+
+// MockStateManager keeps the state in memory
+type MockStateManager struct {
+	mu    sync.Mutex
+	state map[string]any
+}
+
+func (m *MockStateManager) GetState(definitions.StateType) (map[string]any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := make(map[string]any, len(m.state))
+	for k, v := range m.state {
+		state[k] = v
+	}
+	return state, nil
+}
+
+func (m *MockStateManager) SetState(_ definitions.StateType, state map[string]any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+	return nil
+}
+
+func contents(t *testing.T, responses []*definitions.TriggerProcessorResponse) []string {
+	var pages []string
+	for _, response := range responses {
+		reader, err := response.FileHandler.Read()
+		assert.NoError(t, err)
+		page, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		pages = append(pages, string(page))
+	}
+	return pages
+}
+
+func TestPollHTTP_Conditional(t *testing.T) {
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", "Mon, 19 Oct 2026 10:00:00 GMT")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"version": %s}`, etag)
+	}))
+	defer server.Close()
+
+	stateManager := &MockStateManager{}
+	p := NewPollHTTP(stateManager).(*PollHTTP)
+	err := p.SetConfig(map[string]interface{}{
+		"url": server.URL + "/items",
+	})
+	assert.NoError(t, err)
+
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{}}
+	responses, err := p.Execute(info, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"version": "v1"}`}, contents(t, responses))
+	metadata := responses[0].EngineFlowObject.Metadata
+	assert.Equal(t, 200, metadata["PollHTTP.StatusCode"])
+	assert.Equal(t, "application/json", metadata["PollHTTP.ContentType"])
+	assert.Equal(t, server.URL+"/items", metadata["PollHTTP.URL"])
+	assert.Equal(t, 1, metadata["PollHTTP.Page"])
+	assert.Equal(t, map[string]any{
+		"etag":          `"v1"`,
+		"last_modified": "Mon, 19 Oct 2026 10:00:00 GMT",
+	}, stateManager.state[server.URL+"/items"])
+
+	// not modified since the last poll
+	responses, err = p.Execute(info, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Empty(t, responses)
+
+	etag = `"v2"`
+	responses, err = p.Execute(info, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`{"version": "v2"}`}, contents(t, responses))
+}
+
+func TestPollHTTP_Link_Pagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		switch page {
+		case "":
+			w.Header().Set("Link", `</items?page=2>; rel="next", </items?page=3>; rel="last"`)
+		case "2":
+			w.Header().Add("Link", `</items>; rel="first"`)
+			w.Header().Add("Link", `</items?page=3>; rel="next last"`)
+		}
+		_, _ = fmt.Fprintf(w, "page %s", page)
+	}))
+	defer server.Close()
+
+	p := NewPollHTTP(&MockStateManager{}).(*PollHTTP)
+	err := p.SetConfig(map[string]interface{}{
+		"url":        server.URL + "/items",
+		"pagination": map[string]interface{}{"type": "link"},
+	})
+	assert.NoError(t, err)
+
+	responses, err := p.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{}}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"page ", "page 2", "page 3"}, contents(t, responses))
+	assert.Equal(t, 3, responses[2].EngineFlowObject.Metadata["PollHTTP.Page"])
+	assert.Equal(t, server.URL+"/items?page=3", responses[2].EngineFlowObject.Metadata["PollHTTP.URL"])
+
+	err = p.SetConfig(map[string]interface{}{
+		"url":        server.URL + "/items",
+		"pagination": map[string]interface{}{"type": "link", "max_pages": 2},
+	})
+	assert.NoError(t, err)
+	responses, err = p.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{}}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Len(t, responses, 2)
+}
+
+func TestPollHTTP_Max_Pages_Resume(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		switch page {
+		case "":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Header().Set("Link", `</items?page=2>; rel="next"`)
+		case "2":
+			w.Header().Set("Link", `</items?page=3>; rel="next"`)
+		}
+		_, _ = fmt.Fprintf(w, "page %s", page)
+	}))
+	defer server.Close()
+
+	stateManager := &MockStateManager{}
+	p := NewPollHTTP(stateManager).(*PollHTTP)
+	err := p.SetConfig(map[string]interface{}{
+		"url":        server.URL + "/items",
+		"pagination": map[string]interface{}{"type": "link", "max_pages": 2},
+	})
+	assert.NoError(t, err)
+	info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{}}
+
+	responses, err := p.Execute(info, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"page ", "page 2"}, contents(t, responses))
+	assert.Equal(t, map[string]any{
+		"etag":             `"v1"`,
+		"last_modified":    "",
+		"next_page":        server.URL + "/items?page=3",
+		"next_page_number": 3,
+	}, stateManager.state[server.URL+"/items"])
+
+	// the next poll carries on from the page the first one stopped at
+	responses, err = p.Execute(info, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"page 3"}, contents(t, responses))
+	assert.Equal(t, 3, responses[0].EngineFlowObject.Metadata["PollHTTP.Page"])
+	assert.Equal(t, map[string]any{
+		"etag":          `"v1"`,
+		"last_modified": "",
+	}, stateManager.state[server.URL+"/items"])
+
+	// all pages were fetched, so the first one is conditional again
+	responses, err = p.Execute(info, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Empty(t, responses)
+}
+
+func TestPollHTTP_Cursor_Pagination(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Query().Get("after") {
+		case "":
+			_, _ = w.Write([]byte(`{"items": [1, 2], "meta": {"next": "abc"}}`))
+		case "abc":
+			_, _ = w.Write([]byte(`{"items": [3], "meta": {"next": null}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	for _, nextCursor := range []string{"$.meta.next", "body.meta.next"} {
+		p := NewPollHTTP(&MockStateManager{}).(*PollHTTP)
+		err := p.SetConfig(map[string]interface{}{
+			"url":  server.URL + "/items?limit=${Limit}",
+			"auth": map[string]interface{}{"type": "bearer", "token": "secret"},
+			"pagination": map[string]interface{}{
+				"type":         "cursor",
+				"next_cursor":  nextCursor,
+				"cursor_param": "after",
+			},
+		})
+		assert.NoError(t, err)
+
+		info := &definitions.EngineFlowObject{Metadata: map[string]interface{}{"Limit": "2"}}
+		responses, err := p.Execute(info, produceFileHandler, logrus.New())
+		assert.NoError(t, err, nextCursor)
+		assert.Equal(t, []string{
+			`{"items": [1, 2], "meta": {"next": "abc"}}`,
+			`{"items": [3], "meta": {"next": null}}`,
+		}, contents(t, responses), nextCursor)
+		assert.Equal(t, server.URL+"/items?after=abc&limit=2", responses[1].EngineFlowObject.Metadata["PollHTTP.URL"])
+		assert.Equal(t, "2", responses[1].EngineFlowObject.Metadata["Limit"])
+	}
+}
+
+func TestPollHTTP_Numeric_Cursor(t *testing.T) {
+	var cursors []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		after := r.URL.Query().Get("after")
+		cursors = append(cursors, after)
+		switch after {
+		case "":
+			_, _ = w.Write([]byte(`{"next": 1234567, "id": 9007199254740993}`))
+		case "1234567":
+			_, _ = w.Write([]byte(`{"next": 9007199254740993, "id": 9007199254740993}`))
+		default:
+			// the last page hands out its own cursor again
+			_, _ = w.Write([]byte(`{"next": 9007199254740993, "id": 9007199254740993}`))
+		}
+	}))
+	defer server.Close()
+
+	p := NewPollHTTP(&MockStateManager{}).(*PollHTTP)
+	err := p.SetConfig(map[string]interface{}{
+		"url": server.URL + "/items",
+		"pagination": map[string]interface{}{
+			"type":         "cursor",
+			"next_cursor":  "$.next",
+			"cursor_param": "after",
+		},
+	})
+	assert.NoError(t, err)
+	responses, err := p.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{}}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Len(t, responses, 3)
+	assert.Equal(t, []string{"", "1234567", "9007199254740993"}, cursors)
+
+	// numbers computed by an expr are not written in exponent notation either
+	cursors = nil
+	err = p.SetConfig(map[string]interface{}{
+		"url": server.URL + "/items",
+		"pagination": map[string]interface{}{
+			"type":         "cursor",
+			"next_cursor":  "body.next < 2000000 ? body.next : nil",
+			"cursor_param": "after",
+		},
+	})
+	assert.NoError(t, err)
+	responses, err = p.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{}}, produceFileHandler, logrus.New())
+	assert.NoError(t, err)
+	assert.Len(t, responses, 2)
+	assert.Equal(t, []string{"", "1234567"}, cursors)
+}
+
+func TestNextLink(t *testing.T) {
+	for name, tc := range map[string]struct {
+		headers  []string
+		expected string
+	}{
+		"comma in the target": {
+			headers:  []string{`</items?ids=1,2&page=2>; rel="next", </items?ids=1,2&page=9>; rel="last"`},
+			expected: "/items?ids=1,2&page=2",
+		},
+		"quoted parameter with comma and semicolon": {
+			headers:  []string{`</items?page=1>; title="a, b; c"; rel="prev", </items?page=3>; rel=next`},
+			expected: "/items?page=3",
+		},
+		"relation among others": {
+			headers:  []string{`</items?page=2>; REL="last next"`},
+			expected: "/items?page=2",
+		},
+		"spread over headers": {
+			headers:  []string{`</items>; rel="first"`, `</items?page=2>; rel="next"`},
+			expected: "/items?page=2",
+		},
+		"no next": {
+			headers: []string{`</items>; rel="first"`, `garbage`},
+		},
+	} {
+		assert.Equal(t, tc.expected, nextLink(tc.headers), name)
+	}
+}
+
+func TestPollHTTP_Error_Keeps_State(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Link", `</items?page=2>; rel="next"`)
+	}))
+	defer server.Close()
+
+	stateManager := &MockStateManager{}
+	p := NewPollHTTP(stateManager).(*PollHTTP)
+	err := p.SetConfig(map[string]interface{}{
+		"url":        server.URL + "/items",
+		"pagination": map[string]interface{}{"type": "link"},
+	})
+	assert.NoError(t, err)
+
+	_, err = p.Execute(&definitions.EngineFlowObject{Metadata: map[string]interface{}{}}, produceFileHandler, logrus.New())
+	assert.ErrorContains(t, err, "500")
+	assert.Empty(t, stateManager.state)
+}
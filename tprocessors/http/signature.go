@@ -0,0 +1,364 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/httpclient"
+	"github.com/go-streamline/standard-processors-bundle/internal/statevalue"
+	"hash"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type signatureScheme string
+
+const (
+	// schemePlain reads the signature from its own header, and the timestamp from timestamp_header if set.
+	schemePlain signatureScheme = "plain"
+	// schemeStripe reads both from a single header of the form t=<timestamp>,v1=<signature>[,v1=<signature>].
+	schemeStripe signatureScheme = "stripe"
+)
+
+const (
+	noncePrefix               = "nonce:"
+	defaultSignatureHeader    = "X-Signature"
+	defaultSignatureTolerance = 5 * time.Minute
+	defaultNonceTTL           = 24 * time.Hour
+	nonceSweepInterval        = time.Minute
+)
+
+// errNonceInFlight rejects a delivery whose nonce belongs to a request still waiting for its reply,
+// which happens when the provider retries before the first attempt is answered.
+var errNonceInFlight = errors.New("a request with the same nonce is still in flight")
+
+var signatureAlgorithms = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+type signatureConfig struct {
+	Scheme            signatureScheme `mapstructure:"scheme,omitempty"`
+	Secret            string          `mapstructure:"secret,omitempty"`
+	SecretFile        string          `mapstructure:"secret_file,omitempty"`
+	Algorithm         string          `mapstructure:"algorithm,omitempty"` // sha1, sha256 or sha512
+	SignatureHeader   string          `mapstructure:"signature_header,omitempty"`
+	SignaturePrefix   string          `mapstructure:"signature_prefix,omitempty"`   // e.g. sha256=
+	SignatureEncoding string          `mapstructure:"signature_encoding,omitempty"` // hex or base64
+	TimestampHeader   string          `mapstructure:"timestamp_header,omitempty"`   // the body is signed without a timestamp if not set
+	Tolerance         string          `mapstructure:"tolerance,omitempty"`          // maximum age of a timestamp
+	NonceHeader       string          `mapstructure:"nonce_header,omitempty"`       // e.g. X-GitHub-Delivery
+	NonceTTL          string          `mapstructure:"nonce_ttl,omitempty"`
+}
+
+// verifierError is a failure of the verifier itself, as opposed to a request it rejects.
+type verifierError struct {
+	err error
+}
+
+func (e *verifierError) Error() string {
+	return e.err.Error()
+}
+
+func (e *verifierError) Unwrap() error {
+	return e.err
+}
+
+// signatureVerifier checks the HMAC signature of every request before it is accepted, and rejects
+// replayed requests by remembering their nonces in the state. Timestamped requests whose nonce header
+// is not configured use their signature as the nonce, which is unique within the tolerance window.
+// A nonce is only recorded once its request was answered with 2xx, so a delivery that failed can be
+// retried by the provider.
+type signatureVerifier struct {
+	config       signatureConfig
+	hash         func() hash.Hash
+	tolerance    time.Duration
+	nonceTTL     time.Duration
+	stateManager definitions.StateManager
+	stateMu      sync.Mutex
+	state        map[string]any      // the local state, read once and kept in memory
+	inFlight     map[string]struct{} // nonces of the requests waiting for their reply
+	swept        time.Time
+	now          func() time.Time
+}
+
+// newSignatureVerifier returns nil if conf does not enable verification.
+func newSignatureVerifier(conf signatureConfig, stateManager definitions.StateManager) (*signatureVerifier, error) {
+	if conf == (signatureConfig{}) {
+		return nil, nil
+	}
+	if (conf.Secret == "") == (conf.SecretFile == "") {
+		return nil, fmt.Errorf("exactly one of secret or secret_file is required")
+	}
+	v := &signatureVerifier{
+		config:       conf,
+		stateManager: stateManager,
+		inFlight:     make(map[string]struct{}),
+		now:          time.Now,
+	}
+	switch v.config.Scheme {
+	case "":
+		v.config.Scheme = schemePlain
+	case schemePlain, schemeStripe:
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s", conf.Scheme)
+	}
+	if v.config.Scheme == schemeStripe && (conf.TimestampHeader != "" || conf.SignaturePrefix != "") {
+		return nil, fmt.Errorf("timestamp_header and signature_prefix are not supported by the stripe scheme")
+	}
+	if v.config.Algorithm == "" {
+		v.config.Algorithm = "sha256"
+	}
+	var ok bool
+	v.hash, ok = signatureAlgorithms[v.config.Algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %s", conf.Algorithm)
+	}
+	if v.config.SignatureHeader == "" {
+		v.config.SignatureHeader = defaultSignatureHeader
+	}
+	if v.config.SignatureEncoding == "" {
+		v.config.SignatureEncoding = "hex"
+	}
+	if v.config.SignatureEncoding != "hex" && v.config.SignatureEncoding != "base64" {
+		return nil, fmt.Errorf("unsupported signature_encoding %s", conf.SignatureEncoding)
+	}
+
+	var err error
+	v.tolerance, err = httpclient.ParseDuration(conf.Tolerance, defaultSignatureTolerance)
+	if err != nil {
+		return nil, fmt.Errorf("tolerance: %w", err)
+	}
+	// timestamped requests older than the tolerance are rejected anyway, so their nonces need no longer
+	defaultTTL := defaultNonceTTL
+	if v.timestamped() {
+		defaultTTL = v.tolerance
+	}
+	v.nonceTTL, err = httpclient.ParseDuration(conf.NonceTTL, defaultTTL)
+	if err != nil {
+		return nil, fmt.Errorf("nonce_ttl: %w", err)
+	}
+	if v.rejectsReplays() && stateManager == nil {
+		return nil, fmt.Errorf("rejecting replays requires a state manager")
+	}
+	return v, nil
+}
+
+func (v *signatureVerifier) timestamped() bool {
+	return v.config.Scheme == schemeStripe || v.config.TimestampHeader != ""
+}
+
+func (v *signatureVerifier) rejectsReplays() bool {
+	return v.config.NonceHeader != "" || v.timestamped()
+}
+
+// verify returns an error describing why the request is rejected, or nil if its signature is valid
+// and it was not seen before. Failures of the verifier itself are returned as *verifierError. The
+// returned nonce is reserved until it is passed to commitNonce or releaseNonce, and is empty if
+// replays are not rejected.
+func (v *signatureVerifier) verify(r *http.Request, body []byte) (string, error) {
+	timestamp, signatures, err := v.parse(r)
+	if err != nil {
+		return "", err
+	}
+	if v.timestamped() {
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid timestamp %q", timestamp)
+		}
+		age := v.now().Sub(time.Unix(seconds, 0))
+		if age > v.tolerance || age < -v.tolerance {
+			return "", fmt.Errorf("timestamp %s is outside the tolerance of %s", timestamp, v.tolerance)
+		}
+	}
+
+	secret, err := v.secret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(v.hash, secret)
+	if v.timestamped() {
+		mac.Write([]byte(timestamp))
+		mac.Write([]byte("."))
+	}
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	var matched string
+	for _, signature := range signatures {
+		decoded, err := v.decode(signature)
+		if err == nil && hmac.Equal(decoded, expected) {
+			matched = signature
+			break
+		}
+	}
+	if matched == "" {
+		return "", fmt.Errorf("signature mismatch")
+	}
+
+	if !v.rejectsReplays() {
+		return "", nil
+	}
+	nonce := matched
+	if v.config.NonceHeader != "" {
+		nonce = r.Header.Get(v.config.NonceHeader)
+		if nonce == "" {
+			return "", fmt.Errorf("missing %s header", v.config.NonceHeader)
+		}
+	}
+	err = v.reserveNonce(nonce)
+	if err != nil {
+		return "", err
+	}
+	return nonce, nil
+}
+
+// parse returns the timestamp and the candidate signatures of r.
+func (v *signatureVerifier) parse(r *http.Request) (string, []string, error) {
+	value := r.Header.Get(v.config.SignatureHeader)
+	if value == "" {
+		return "", nil, fmt.Errorf("missing %s header", v.config.SignatureHeader)
+	}
+	if v.config.Scheme == schemeStripe {
+		var timestamp string
+		var signatures []string
+		for _, item := range strings.Split(value, ",") {
+			key, itemValue, _ := strings.Cut(strings.TrimSpace(item), "=")
+			switch key {
+			case "t":
+				timestamp = itemValue
+			case "v1":
+				signatures = append(signatures, itemValue)
+			}
+		}
+		if timestamp == "" || len(signatures) == 0 {
+			return "", nil, fmt.Errorf("malformed %s header", v.config.SignatureHeader)
+		}
+		return timestamp, signatures, nil
+	}
+
+	signature, ok := strings.CutPrefix(value, v.config.SignaturePrefix)
+	if !ok {
+		return "", nil, fmt.Errorf("%s header does not start with %s", v.config.SignatureHeader, v.config.SignaturePrefix)
+	}
+	var timestamp string
+	if v.config.TimestampHeader != "" {
+		timestamp = r.Header.Get(v.config.TimestampHeader)
+		if timestamp == "" {
+			return "", nil, fmt.Errorf("missing %s header", v.config.TimestampHeader)
+		}
+	}
+	return timestamp, []string{signature}, nil
+}
+
+func (v *signatureVerifier) secret() ([]byte, error) {
+	if v.config.SecretFile != "" {
+		// a webhook secret rolled by the provider is written to the file, and the next delivery is
+		// verified with it without restarting the listener
+		secret, err := os.ReadFile(v.config.SecretFile)
+		if err != nil {
+			return nil, &verifierError{fmt.Errorf("failed to read secret file: %w", err)}
+		}
+		return []byte(strings.TrimSpace(string(secret))), nil
+	}
+	return []byte(v.config.Secret), nil
+}
+
+func (v *signatureVerifier) decode(signature string) ([]byte, error) {
+	if v.config.SignatureEncoding == "base64" {
+		return base64.StdEncoding.DecodeString(signature)
+	}
+	return hex.DecodeString(signature)
+}
+
+// reserveNonce rejects a delivery whose nonce was already answered with 2xx and has not expired, or
+// whose nonce belongs to a request still in flight. Otherwise the nonce is held for this request.
+func (v *signatureVerifier) reserveNonce(nonce string) error {
+	v.stateMu.Lock()
+	defer v.stateMu.Unlock()
+	state, err := v.localState()
+	if err != nil {
+		return err
+	}
+	if expiresAt, ok := statevalue.Int64(state[noncePrefix+nonce]); ok && v.now().Unix() < expiresAt {
+		return fmt.Errorf("nonce %s was already used", nonce)
+	}
+	if _, ok := v.inFlight[nonce]; ok {
+		return errNonceInFlight
+	}
+	v.inFlight[nonce] = struct{}{}
+	return nil
+}
+
+// commitNonce records the nonce of a request answered with 2xx until the nonce TTL passes, and writes
+// it to the state manager, so replays are still rejected after a restart. Nonces past their TTL can
+// no longer match a valid delivery, and are removed once per nonceSweepInterval.
+func (v *signatureVerifier) commitNonce(nonce string) error {
+	if nonce == "" {
+		return nil
+	}
+	v.stateMu.Lock()
+	defer v.stateMu.Unlock()
+	delete(v.inFlight, nonce)
+	state, err := v.localState()
+	if err != nil {
+		return err
+	}
+	now := v.now()
+	if now.Sub(v.swept) >= nonceSweepInterval {
+		for key, value := range state {
+			if !strings.HasPrefix(key, noncePrefix) {
+				continue
+			}
+			if expiresAt, ok := statevalue.Int64(value); ok && now.Unix() >= expiresAt {
+				delete(state, key)
+			}
+		}
+		v.swept = now
+	}
+	state[noncePrefix+nonce] = now.Add(v.nonceTTL).Unix()
+	err = v.stateManager.SetState(definitions.StateTypeLocal, state)
+	if err != nil {
+		delete(state, noncePrefix+nonce)
+		return &verifierError{fmt.Errorf("failed to set state: %w", err)}
+	}
+	return nil
+}
+
+// releaseNonce gives up the nonce of a request that was not answered with 2xx, so the provider can
+// deliver it again.
+func (v *signatureVerifier) releaseNonce(nonce string) {
+	if nonce == "" {
+		return
+	}
+	v.stateMu.Lock()
+	defer v.stateMu.Unlock()
+	delete(v.inFlight, nonce)
+}
+
+// localState returns the local state, reading it from the state manager on first use only. The
+// verifier is the only writer of the state of its listener. v.stateMu must be held.
+func (v *signatureVerifier) localState() (map[string]any, error) {
+	if v.state != nil {
+		return v.state, nil
+	}
+	state, err := v.stateManager.GetState(definitions.StateTypeLocal)
+	if err != nil {
+		return nil, &verifierError{fmt.Errorf("failed to get state: %w", err)}
+	}
+	if state == nil {
+		state = make(map[string]any)
+	}
+	v.state = state
+	return state, nil
+}
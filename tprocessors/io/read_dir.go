@@ -1,6 +1,7 @@
 package io
 
 import (
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
 	"github.com/sirupsen/logrus"
 	"io"
@@ -14,6 +15,7 @@ type ReadDir struct {
 	definitions.BaseProcessor
 	config       *readDirConfig
 	stateManager definitions.StateManager
+	exprOptions  []expr.Option
 }
 
 type readDirConfig struct {
@@ -27,9 +29,10 @@ func (r *ReadDir) GetScheduleType() definitions.ScheduleType {
 	return definitions.CronDriven
 }
 
-func NewReadDir(stateManager definitions.StateManager) definitions.TriggerProcessor {
+func NewReadDir(stateManager definitions.StateManager, exprOptions ...expr.Option) definitions.TriggerProcessor {
 	return &ReadDir{
 		stateManager: stateManager,
+		exprOptions:  exprOptions,
 	}
 }
 
@@ -104,7 +107,7 @@ func (r *ReadDir) Execute(
 	log.Trace("handling ReadDir")
 
 	log.Debugf("evaluating expression %s", r.config.Input)
-	inputPath, err := info.EvaluateExpression(r.config.Input)
+	inputPath, err := info.EvaluateExpression(r.config.Input, r.exprOptions...)
 	if err != nil {
 		return nil, err
 	}
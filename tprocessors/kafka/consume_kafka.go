@@ -5,16 +5,18 @@ import (
 	"fmt"
 	"github.com/IBM/sarama"
 	"github.com/go-streamline/interfaces/definitions"
+	"github.com/go-streamline/standard-processors-bundle/internal/kafkaclient"
 	"github.com/sirupsen/logrus"
 	"strings"
 )
 
 type ConsumeKafka struct {
 	definitions.BaseProcessor
-	config        *consumeKafkaConfig
-	consumerGroup sarama.ConsumerGroup
-	ctx           context.Context
-	cancel        context.CancelFunc
+	config           *consumeKafkaConfig
+	consumerGroup    sarama.ConsumerGroup
+	ctx              context.Context
+	cancel           context.CancelFunc
+	newConsumerGroup func(addrs []string, groupID string, config *sarama.Config) (sarama.ConsumerGroup, error)
 }
 
 type consumeKafkaConfig struct {
@@ -23,10 +25,13 @@ type consumeKafkaConfig struct {
 	ConsumerGroup    string `mapstructure:"consumer_group"`
 	Version          string `mapstructure:"kafka_version"`
 	StartFromOldest  bool   `mapstructure:"start_from_oldest"`
+	IsolationLevel   string `mapstructure:"isolation_level,omitempty"` // read_uncommitted or read_committed
+
+	kafkaclient.SecurityConfig `mapstructure:",squash"`
 }
 
 func NewConsumeKafka() definitions.TriggerProcessor {
-	c := &ConsumeKafka{}
+	c := &ConsumeKafka{newConsumerGroup: sarama.NewConsumerGroup}
 	c.ctx, c.cancel = context.WithCancel(context.Background())
 	return c
 }
@@ -117,7 +122,22 @@ func (c *ConsumeKafka) SetConfig(conf map[string]interface{}) error {
 		consumerConfig.Consumer.Offsets.Initial = sarama.OffsetNewest
 	}
 
-	c.consumerGroup, err = sarama.NewConsumerGroup(strings.Split(c.config.BootstrapServers, ","), c.config.ConsumerGroup, consumerConfig)
+	switch strings.ToLower(c.config.IsolationLevel) {
+	case "", "read_uncommitted":
+		consumerConfig.Consumer.IsolationLevel = sarama.ReadUncommitted
+	case "read_committed":
+		// skips the messages of aborted transactions, and waits for open ones to finish
+		consumerConfig.Consumer.IsolationLevel = sarama.ReadCommitted
+	default:
+		return fmt.Errorf("invalid isolation_level value: %s", c.config.IsolationLevel)
+	}
+
+	err = c.config.SecurityConfig.Apply(consumerConfig)
+	if err != nil {
+		return err
+	}
+
+	c.consumerGroup, err = c.newConsumerGroup(strings.Split(c.config.BootstrapServers, ","), c.config.ConsumerGroup, consumerConfig)
 	if err != nil {
 		return err
 	}
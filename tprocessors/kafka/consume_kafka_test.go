@@ -0,0 +1,38 @@
+package kafka
+
+import (
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestConsumeKafka_Isolation_Level(t *testing.T) {
+	var config *sarama.Config
+	c := NewConsumeKafka().(*ConsumeKafka)
+	c.newConsumerGroup = func(_ []string, _ string, conf *sarama.Config) (sarama.ConsumerGroup, error) {
+		config = conf
+		return nil, nil
+	}
+	conf := func(isolationLevel string) map[string]interface{} {
+		return map[string]interface{}{
+			"bootstrap_servers": "localhost:9092",
+			"consumer_group":    "billing",
+			"topic_names":       "invoices",
+			"kafka_version":     "3.6.0",
+			"isolation_level":   isolationLevel,
+		}
+	}
+
+	for isolationLevel, expected := range map[string]sarama.IsolationLevel{
+		"":                 sarama.ReadUncommitted,
+		"read_uncommitted": sarama.ReadUncommitted,
+		"read_committed":   sarama.ReadCommitted,
+	} {
+		err := c.SetConfig(conf(isolationLevel))
+		assert.NoError(t, err, isolationLevel)
+		assert.Equal(t, expected, config.Consumer.IsolationLevel, isolationLevel)
+	}
+
+	err := c.SetConfig(conf("serializable"))
+	assert.ErrorContains(t, err, "invalid isolation_level value")
+}
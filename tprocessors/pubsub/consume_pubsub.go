@@ -4,8 +4,9 @@ import (
 	"cloud.google.com/go/pubsub"
 	"context"
 	"fmt"
+	"github.com/expr-lang/expr"
 	"github.com/go-streamline/interfaces/definitions"
-	"github.com/go-streamline/interfaces/utils"
+	"github.com/go-streamline/standard-processors-bundle/internal/expression"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"google.golang.org/api/option"
@@ -18,6 +19,7 @@ type ConsumePubSub struct {
 	client       *pubsub.Client
 	subscription *pubsub.Subscription
 	messages     map[string]*pubsub.Message
+	exprOptions  []expr.Option
 }
 
 type consumePubSuConfig struct {
@@ -29,9 +31,10 @@ type consumePubSuConfig struct {
 	AckImmediately   bool   `mapstructure:"ack_immediately"` // whether to ack upon receiving the message or after getting a finishing session update
 }
 
-func NewConsumePubSub() definitions.TriggerProcessor {
+func NewConsumePubSub(exprOptions ...expr.Option) definitions.TriggerProcessor {
 	return &ConsumePubSub{
-		ctx: context.Background(),
+		ctx:         context.Background(),
+		exprOptions: exprOptions,
 	}
 }
 
@@ -47,7 +50,12 @@ func (c *ConsumePubSub) SetConfig(config map[string]interface{}) error {
 		return err
 	}
 	c.config = conf
-	credentials, err := utils.EvaluateExpression(c.config.Credentials, nil)
+	credentialsExpr, err := expression.Compile(c.config.Credentials, c.exprOptions...)
+	if err != nil {
+		logrus.WithError(err).Errorf("failed to compile credentials expression")
+		return err
+	}
+	credentials, err := credentialsExpr.Evaluate(nil)
 	if err != nil {
 		logrus.WithError(err).Errorf("failed to evaluate credentials expression")
 		return err